@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/oras"
+)
+
+const usage = `Usage: compat-cache [--cache <dir>] <command>
+
+Commands:
+  list     List cached artifacts, most recently used first
+  prune    Remove artifacts not used within --older-than, and evict to --max-size
+  verify   Check cached content against its digest, removing corrupt entries
+`
+
+var (
+	cacheDir  string
+	olderThan time.Duration
+	maxSize   int64
+)
+
+func main() {
+	flag.StringVar(&cacheDir, "cache", "", "Cache directory used by the compatibility server")
+	flag.DurationVar(&olderThan, "older-than", 0, "For prune, remove artifacts not used within this duration (0 to skip)")
+	flag.Int64Var(&maxSize, "max-size", 0, "For prune, evict least recently used artifacts over this size in bytes (0 to skip)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage+"\nOptions:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) != 1 || cacheDir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// Don't create a cache that isn't there
+	_, err := os.Stat(cacheDir)
+	if err != nil {
+		log.Fatalf("cannot open cache: %s", err)
+	}
+	cache, err := oras.NewCache(cacheDir, oras.CacheOptions{MaxSize: maxSize})
+	if err != nil {
+		log.Fatalf("cannot open cache: %s", err)
+	}
+
+	switch args[0] {
+	case "list":
+		list(cache)
+	case "prune":
+		removed, err := cache.Prune(olderThan)
+		if err != nil {
+			log.Fatalf("error pruning cache: %s", err)
+		}
+		for _, entry := range removed {
+			fmt.Printf("removed %s\n", entry.Digest)
+		}
+		fmt.Printf("%d artifacts pruned\n", len(removed))
+	case "verify":
+		invalid, err := cache.Verify()
+		if err != nil {
+			log.Fatalf("error verifying cache: %s", err)
+		}
+		for _, entry := range invalid {
+			fmt.Printf("invalid %s (removed)\n", entry.Digest)
+		}
+		if len(invalid) > 0 {
+			os.Exit(1)
+		}
+		fmt.Println("cache content is valid")
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// list prints cached entries and the tags that resolve to them
+func list(cache *oras.Cache) {
+	index, err := cache.ReadIndex()
+	if err != nil {
+		log.Fatalf("error reading cache: %s", err)
+	}
+	entries, err := cache.List()
+	if err != nil {
+		log.Fatalf("error reading cache: %s", err)
+	}
+	total := int64(0)
+	for _, entry := range entries {
+		total += entry.Size
+		fmt.Printf("%s  %8d bytes  last used %s\n", entry.Digest, entry.Size, entry.Accessed.Format(time.RFC3339))
+		for reference, tag := range index.Tags {
+			if tag.Digest == entry.Digest {
+				fmt.Printf("  %s (resolved %s)\n", reference, tag.Resolved.Format(time.RFC3339))
+			}
+		}
+	}
+	fmt.Printf("%d artifacts, %d bytes\n", len(entries), total)
+}
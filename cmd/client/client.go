@@ -2,29 +2,122 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/compspec/compat-lib/pkg/client"
+	"github.com/compspec/compat-lib/pkg/compat"
+	pb "github.com/compspec/compat-lib/protos"
 )
 
 var (
-	host string
+	host   string
+	asJson bool
 )
 
+// Output is the json form of a response
+type Output struct {
+	Status     string                      `json:"status"`
+	Compatible bool                        `json:"compatible"`
+	Result     *compat.CompatibilityResult `json:"result"`
+}
+
 func main() {
 	flag.StringVar(&host, "host", ":50051", "Server address (host:port)")
+	flag.BoolVar(&asJson, "json", false, "Print the result as json")
 	flag.Parse()
 	args := flag.Args()
 
 	if len(args) == 0 {
-		log.Fatal("Please provide a compatibility artifact to compare with the host.")
+		log.Fatal("Please provide a compatibility artifact (json file or registry URI) to compare with the host.")
 	}
-	client, err := client.NewClient(host)
+	cli, err := client.NewClient(host)
 	if err != nil {
 		fmt.Println(err)
 		log.Fatal("Issue creating client")
 	}
-	client.CheckCompatibility(context.Background(), args[0])
+	defer cli.Close()
+
+	response, err := cli.CheckCompatibility(context.Background(), args[0])
+	if err != nil {
+		fmt.Println(err)
+		log.Fatal("Issue checking compatibility")
+	}
+
+	// The payload has details of the result
+	result := compat.NewCompatibilityResult()
+	if response.Payload != "" {
+		err = json.Unmarshal([]byte(response.Payload), result)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatal("Issue parsing response payload")
+		}
+	}
+	if asJson {
+		printJson(response, result)
+	} else {
+		printResult(args[0], response, result)
+	}
+
+	// Exit non-zero so scripts can gate on the result
+	if !response.Compatible {
+		cli.Close()
+		os.Exit(1)
+	}
+}
+
+// printJson prints the response and result as json
+func printJson(response *pb.Response, result *compat.CompatibilityResult) {
+	output := Output{
+		Status:     response.Status.String(),
+		Compatible: response.Compatible,
+		Result:     result,
+	}
+	out, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		fmt.Println(err)
+		log.Fatal("Issue serializing result to json")
+	}
+	fmt.Println(string(out))
+}
+
+// printResult prints a human readable verdict
+func printResult(tocheck string, response *pb.Response, result *compat.CompatibilityResult) {
+	if response.Status == pb.Response_ERROR {
+		fmt.Printf("⚠️  Error checking %s: %s\n", tocheck, result.Message)
+		return
+	}
+	if response.Compatible {
+		fmt.Printf("✅ %s is compatible with %s\n", tocheck, host)
+	} else {
+		fmt.Printf("❌ %s is not compatible with %s\n", tocheck, host)
+	}
+
+	for _, reason := range result.Incompatible {
+		fmt.Printf("   %s\n", reason)
+	}
+	sonames := []string{}
+	for soname := range result.Found {
+		sonames = append(sonames, soname)
+	}
+	sort.Strings(sonames)
+	for _, soname := range sonames {
+		fmt.Printf("   found   %s: %s\n", soname, result.Found[soname][0])
+	}
+	for _, soname := range result.Missing {
+		fmt.Printf("   missing %s\n", soname)
+	}
+	sonames = []string{}
+	for soname := range result.MissingVersions {
+		sonames = append(sonames, soname)
+	}
+	sort.Strings(sonames)
+	for _, soname := range sonames {
+		fmt.Printf("   missing %s from %s\n", strings.Join(result.MissingVersions[soname], ", "), soname)
+	}
 }
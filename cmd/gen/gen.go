@@ -6,23 +6,90 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/compspec/compat-lib/pkg/compat"
 	"github.com/compspec/compat-lib/pkg/generate"
+	"github.com/compspec/compat-lib/pkg/oras"
 )
 
 func main() {
 	fmt.Println("⭐️ Compatibility Library Generator (clib-gen)")
 	outfile := flag.String("out", "", "Output file path for artifact")
+	rootfs := flag.String("rootfs", "", "Generate an artifact for an unpacked container rootfs directory")
+	layout := flag.String("oci-layout", "", "Generate an artifact for an image in an OCI image layout directory")
+	layoutRef := flag.String("ref", "latest", "Reference (tag or digest) of the image in the OCI image layout")
+	image := flag.String("image", "", "Generate an artifact for an image pulled from a registry")
+	push := flag.String("push", "", "Push the artifact to a registry reference (e.g., ghcr.io/org/app:compat)")
+	index := flag.String("index", "", "Push an image index of existing specs (comma separated files, one per platform) to --push")
+	subject := flag.String("subject", "", "Attach the pushed artifact as a referrer of this image (same repository)")
+	mediaType := flag.String("media-type", oras.CompatibilityMediaType, "Media type of the pushed compatibility layer")
+	plainHttp := flag.Bool("plain-http", false, "Use plain http to connect to the registry (e.g., a local registry)")
+	registryConfig := flag.String("registry-config", "", "Docker config.json with registry credentials (defaults to the docker config)")
+	caFile := flag.String("ca-file", "", "PEM bundle of certificate authorities to trust for the registry")
 
 	flag.Parse()
 	args := flag.Args()
 	outPath := *outfile
+	registryOpts := oras.RegistryOptions{
+		PlainHTTP:  *plainHttp,
+		ConfigPath: *registryConfig,
+		CAFile:     *caFile,
+	}
 
-	if len(args) == 0 {
-		log.Fatal("Please provide the binary you want to generate an artifact for.")
+	if *index != "" {
+		if *push == "" {
+			log.Fatal("Please provide a registry reference to --push the index to.")
+		}
+		pushIndex(strings.Split(*index, ","), *push, *mediaType, *subject, registryOpts)
+		return
+	}
+
+	var spec *compat.CompatibiitySpec
+	if *rootfs != "" || *layout != "" || *image != "" {
+		spec = generateContainer(*rootfs, *layout, *layoutRef, *image, registryOpts)
+	} else {
+		if len(args) == 0 {
+			log.Fatal("Please provide the binary you want to generate an artifact for.")
+		}
+		spec = generateBinary(args)
+	}
+	err := spec.Validate()
+	if err != nil {
+		fmt.Println(err)
+		log.Fatalf("Generated spec is not valid")
 	}
 
+	out, err := spec.ToJson()
+	if err != nil {
+		fmt.Println(err)
+		log.Fatalf("Issue serializing spec to json")
+	}
+	if outPath == "" {
+		fmt.Println(string(out))
+	} else {
+		fmt.Printf("🗒️ Writing to file %s\n", outPath)
+		err = os.WriteFile(outPath, out, 0644)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatalf("Issue writing to output file")
+		}
+	}
+
+	if *push != "" {
+		fmt.Printf("📦️ Pushing to %s\n", *push)
+		desc, err := oras.PushArtifact(spec, *push, *mediaType, *subject, registryOpts)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatalf("Issue pushing artifact to %s", *push)
+		}
+		fmt.Printf("Pushed %s\n", desc.Digest)
+	}
+}
+
+// generateBinary generates a spec for a single binary
+func generateBinary(args []string) *compat.CompatibiitySpec {
+
 	// Get the full path of the command
 	path := args[0]
 	path, err := filepath.Abs(path)
@@ -39,21 +106,100 @@ func main() {
 		log.Fatalf("Error finding shared libraries for %s", path)
 	}
 
-	// Generate the artifact
-	spec := compat.GenerateLibraryArtifact(path, libs)
-	out, err := spec.ToJson()
+	// Symbol versions (e.g., GLIBC_2.34) needed from each library, by the
+	// binary and the libraries it loads
+	needs, err := generate.ReadAllVersionNeeds(path)
 	if err != nil {
 		fmt.Println(err)
-		log.Fatalf("Issue serializing spec to json")
+		log.Fatalf("Error reading symbol versions needed by %s", path)
 	}
-	if outPath == "" {
-		fmt.Println(string(out))
+
+	// Machine, class, endianness, OS/ABI, interpreter, and minimum kernel
+	props, err := generate.ReadBinaryProperties(path)
+	if err != nil {
+		fmt.Println(err)
+		log.Fatalf("Error reading ELF properties of %s", path)
+	}
+
+	// Generate the artifact
+	return compat.GenerateLibraryArtifact(path, libs, needs, props)
+}
+
+// generateContainer generates a spec for a rootfs, OCI layout, or image
+// An image is pulled into a temporary OCI layout, and a layout is
+// unpacked into a temporary rootfs.
+func generateContainer(rootfs, layout, ref, image string, opts oras.RegistryOptions) *compat.CompatibiitySpec {
+	name := rootfs
+	if image != "" {
+		tmpdir, err := os.MkdirTemp("", "compat-gen-layout")
+		if err != nil {
+			fmt.Println(err)
+			log.Fatal("Error creating temporary directory for image")
+		}
+		defer os.RemoveAll(tmpdir)
+
+		fmt.Printf("Pulling image %s\n", image)
+		ref, err = oras.PullImage(image, tmpdir, opts)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatalf("Error pulling image %s", image)
+		}
+		name, layout = image, tmpdir
+	} else if layout != "" {
+		name = fmt.Sprintf("%s:%s", filepath.Base(layout), ref)
+	}
+
+	if layout != "" {
+		tmpdir, err := os.MkdirTemp("", "compat-gen-rootfs")
+		if err != nil {
+			fmt.Println(err)
+			log.Fatal("Error creating temporary directory for rootfs")
+		}
+		defer os.RemoveAll(tmpdir)
+
+		fmt.Printf("Unpacking image %s\n", name)
+		err = oras.UnpackImage(layout, ref, tmpdir)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatalf("Error unpacking image %s", name)
+		}
+		rootfs = tmpdir
 	} else {
-		fmt.Printf("🗒️ Writing to file %s\n", outPath)
-		err = os.WriteFile(outPath, out, 0644)
+		name = filepath.Base(filepath.Clean(rootfs))
+	}
+
+	fmt.Printf("Preparing to find executables and shared libraries in %s\n", name)
+	scanned, err := generate.ScanRootfs(rootfs)
+	if err != nil {
+		fmt.Println(err)
+		log.Fatalf("Error scanning rootfs for %s", name)
+	}
+	fmt.Printf("Found %d executables and %d bundled libraries\n", len(scanned.Executables), len(scanned.Provided))
+	return scanned.Artifact(name)
+}
+
+// pushIndex pushes an image index of specs, one per platform
+func pushIndex(paths []string, uri, mediaType, subject string, opts oras.RegistryOptions) {
+	specs := []*compat.CompatibiitySpec{}
+	for _, path := range paths {
+		spec, err := compat.LoadFile(path)
 		if err != nil {
 			fmt.Println(err)
-			log.Fatalf("Issue writing to output file")
+			log.Fatalf("Issue loading spec %s", path)
 		}
+		platform, err := oras.SpecPlatform(spec)
+		if err != nil {
+			fmt.Println(err)
+			log.Fatalf("Issue deriving platform for %s", path)
+		}
+		fmt.Printf("   %s: %s\n", oras.PlatformString(platform), path)
+		specs = append(specs, spec)
+	}
+	fmt.Printf("📦️ Pushing index to %s\n", uri)
+	desc, err := oras.PushIndex(specs, uri, mediaType, subject, opts)
+	if err != nil {
+		fmt.Println(err)
+		log.Fatalf("Issue pushing index to %s", uri)
 	}
+	fmt.Printf("Pushed %s\n", desc.Digest)
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/diff"
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// Formats the difference can be written in
+var diffFormats = []string{"text", "json"}
+
+// runDiff compares the paths and timing of two recordings
+// fs-record diff [options] a.log b.log
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fs-record diff [options] <a> <b>\n")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "text", "Format of the difference: "+strings.Join(diffFormats, ", "))
+	events := flags.String("events", event.Open, "Event types to compare (comma separated, or all): "+strings.Join(event.Types, ", "))
+	failed := flags.Bool("failed", false, "Include failed operations (e.g., lookups of missing libraries)")
+	top := flags.Int("top", 10, "Number of largest timing changes to show (text format)")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !slices.Contains(diffFormats, *format) {
+		log.Fatalf("unknown format %s, choices are %s", *format, strings.Join(diffFormats, ", "))
+	}
+	mask, err := event.ParseMask(*events)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := diff.Options{Events: mask, Failed: *failed}
+
+	recordings := []*diff.Recording{}
+	for _, path := range flags.Args() {
+		events, err := event.ReadFile(path)
+		if err != nil {
+			log.Fatalf("cannot read %s: %s", path, err)
+		}
+		recordings = append(recordings, diff.NewRecording(path, events, options))
+	}
+	result := diff.Compare(recordings[0], recordings[1])
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(result)
+	} else {
+		err = result.WriteText(os.Stdout, *top)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	"github.com/compspec/compat-lib/pkg/export"
+)
+
+// runExport converts a recording to a format for other tools
+// fs-record export [options] a.log
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fs-record export [options] <recording>\n")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", export.FormatPerfetto, "Format to export: "+strings.Join(export.Formats, ", ")+" (Chrome trace json for ui.perfetto.dev)")
+	outfile := flags.String("out", "", "Output file (unset for stdout)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !slices.Contains(export.Formats, *format) {
+		log.Fatalf("unknown format %s, choices are %s", *format, strings.Join(export.Formats, ", "))
+	}
+	path := flags.Arg(0)
+	events, err := event.ReadFile(path)
+	if err != nil {
+		log.Fatalf("cannot read %s: %s", path, err)
+	}
+
+	out := os.Stdout
+	if *outfile != "" {
+		out, err = os.Create(*outfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer out.Close()
+	}
+	err = export.Perfetto(out, path, events)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
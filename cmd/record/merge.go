@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	"github.com/compspec/compat-lib/pkg/mpi"
+)
+
+// runMerge merges the recordings of MPI ranks into one timeline
+// fs-record merge [options] rank-0.log rank-1.log ...
+func runMerge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: fs-record merge [options] <recording>...\n")
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", event.FormatJSON, "Format of the timeline: "+strings.Join(event.Formats, ", "))
+	outfile := flags.String("out", "", "Output file (unset for stdout)")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if !slices.Contains(event.Formats, *format) {
+		log.Fatalf("unknown format %s, choices are %s", *format, strings.Join(event.Formats, ", "))
+	}
+
+	timeline, ranks, err := merge(flags.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// A binary file needs its header, which a new file is given
+	var writer event.Writer
+	if *outfile != "" {
+		os.Remove(*outfile)
+		writer, err = event.OpenFile(*outfile, *format)
+	} else if *format == event.FormatBinary {
+		log.Fatal("a binary timeline must be written to a file with --out")
+	} else {
+		writer, err = event.NewWriter(os.Stdout, *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range timeline {
+		err = writer.Write(e)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d events from %d ranks\n", len(timeline), ranks)
+}
+
+// merge reads the recordings of ranks into one timeline, and returns it
+// with the number of ranks. Events without a rank (e.g., text from an
+// older fs-record) have the rank in the name of their file.
+func merge(paths []string) ([]*event.Event, int, error) {
+	timeline := []*event.Event{}
+	ranks := map[string]bool{}
+	for _, path := range paths {
+		events, err := event.ReadFile(path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot read %s: %s", path, err)
+		}
+		rank := mpi.PathRank(path)
+		for _, e := range events {
+			if e.Rank == "" {
+				e.Rank = rank
+			}
+			if e.Rank == "" {
+				return nil, 0, fmt.Errorf("cannot find the rank of events in %s (from the events, or a rank-N name)", path)
+			}
+			ranks[e.Rank] = true
+		}
+		timeline = append(timeline, events...)
+	}
+
+	// Events at the same time keep the order of their recordings
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Timestamp < timeline[j].Timestamp
+	})
+	return timeline, len(ranks), nil
+}
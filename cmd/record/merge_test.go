@@ -0,0 +1,78 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// record writes the events of a rank to a file in a format
+func record(t *testing.T, path, format string, events ...*event.Event) {
+	writer, err := event.OpenFile(path, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events {
+		err = writer.Write(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+
+	// Rank 0 has its rank in its events, and rank 1 (an older text
+	// recording) only in the name of its file
+	zero := filepath.Join(dir, "lammps.jsonl")
+	record(t, zero, event.FormatJSON,
+		&event.Event{Timestamp: 100, Type: event.Open, Path: "/in.lj", Rank: "0"},
+		&event.Event{Timestamp: 300, Type: event.Open, Path: "/data.0", Rank: "0"},
+		&event.Event{Timestamp: 500, Type: event.Close, Path: "/data.0", Rank: "0"},
+	)
+	one := filepath.Join(dir, "lammps.rank-1.log")
+	record(t, one, event.FormatText,
+		&event.Event{Timestamp: 200, Type: event.Open, Path: "/in.lj"},
+		&event.Event{Timestamp: 300, Type: event.Open, Path: "/data.1"},
+	)
+
+	timeline, ranks, err := merge([]string{zero, one})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ranks != 2 {
+		t.Errorf("merged %d ranks, want 2", ranks)
+	}
+
+	// Events at the same time keep the order of the recordings
+	want := []string{"0 /in.lj", "1 /in.lj", "0 /data.0", "1 /data.1", "0 /data.0"}
+	got := []string{}
+	for _, e := range timeline {
+		got = append(got, e.Rank+" "+e.Path)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("merged %v, want %v", got, want)
+	}
+}
+
+func TestMergeWithoutRank(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lammps.log")
+	record(t, path, event.FormatText, &event.Event{Timestamp: 100, Type: event.Open, Path: "/in.lj"})
+
+	_, _, err := merge([]string{path})
+	if err == nil || !strings.Contains(err.Error(), "cannot find the rank") {
+		t.Errorf("merge() = %v, want an error for the missing rank", err)
+	}
+	_, _, err = merge([]string{filepath.Join(dir, "missing.rank-2.log")})
+	if err == nil || !strings.Contains(err.Error(), "cannot read") {
+		t.Errorf("merge() = %v, want an error for the missing file", err)
+	}
+}
@@ -6,50 +6,128 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/compspec/compat-lib/pkg/event"
+	defaults "github.com/compspec/compat-lib/pkg/fs"
 	fs "github.com/compspec/compat-lib/pkg/fs/record"
 	"github.com/compspec/compat-lib/pkg/logger"
+	"github.com/compspec/compat-lib/pkg/mpi"
+	"github.com/compspec/compat-lib/pkg/runner"
+	"github.com/compspec/compat-lib/pkg/session"
+	"github.com/compspec/compat-lib/pkg/stream"
 	"github.com/compspec/compat-lib/pkg/utils"
 )
 
-// getRank of the running process given an mpi context
-func getRank() string {
+// Subcommands of fs-record that work on recordings
+var subcommands = map[string]func(args []string){
+	"diff":   runDiff,
+	"export": runExport,
+	"merge":  runMerge,
+}
+
+// How ranks mount under MPI: each rank mounts its own filesystem, or the
+// lead rank on a node mounts one that the others share
+const (
+	mountLocal  = "local"
+	mountShared = "shared"
+)
 
-	// OpenMPI
-	rank := os.Getenv("OMPI_COMM_WORLD_RANK")
+var mpiMounts = []string{mountLocal, mountShared}
+
+// How long ranks sharing a mount wait for the lead rank to mount it, and
+// how long the lead waits for the others once its own command is done
+const (
+	readyTimeout = 10 * time.Minute
+	doneTimeout  = 10 * time.Minute
+)
 
-	// MPICH/IntelMPI via PMI
-	if rank == "" {
-		rank = os.Getenv("PMI_RANK")
+// runShared runs the command of a rank in the mount of its lead rank, and
+// returns its exit code. The lead keeps the mount until every rank is done
+// or failed, so each way out tells it which.
+func runShared(shared *mpi.Shared, runWith, mountPath, rank string, args []string) int {
+	path, err := utils.FullPath(args[0])
+	if err == nil {
+		args[0] = path
+		err = shared.WaitReady(readyTimeout)
+	}
+	if err != nil {
+		fmt.Println(err)
+		failErr := shared.Failed(rank)
+		if failErr != nil {
+			fmt.Printf("cannot tell the lead rank this rank failed: %s\n", failErr)
+		}
+		return 1
+	}
+	used, err := runner.Run(runWith, mountPath, args)
+	if used != "" {
+		fmt.Printf("Command was run with %s\n", used)
+	}
+	mark := shared.Done
+	if err != nil {
+		fmt.Println(err)
+		mark = shared.Failed
 	}
-	// Slurm
-	if rank == "" {
-		rank = os.Getenv("SLURM_PROCID")
+	markErr := mark(rank)
+	if markErr != nil {
+		fmt.Printf("cannot tell the lead rank this rank is done: %s\n", markErr)
 	}
+	return runner.ExitCode(err)
+}
 
-	// Flux
-	if rank == "" {
-		rank = os.Getenv("FLUX_TASK_RANK")
+// newFilter creates a filter for callers from comma separated lists
+func newFilter(pids, uids, comms string) (*defaults.Filter, error) {
+	filter := defaults.Filter{}
+	var err error
+	filter.Pids, err = utils.SplitInts(pids)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --pid: %w", err)
 	}
-	if rank == "0" {
-		fmt.Printf("MPI Rank %s (or master) preparing to create fuseFS and launch LAMMPS via PRoot.\n", rank)
-	} else {
-		fmt.Printf("MPI Rank %s preparing to just PRoot.\n", rank)
+	filter.Uids, err = utils.SplitInts(uids)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --uid: %w", err)
 	}
-	return rank
+	if comms != "" {
+		filter.Comms = strings.Split(comms, ",")
+	}
+	return &filter, nil
 }
 
 func main() {
+
+	// In the namespaces of the unshare runner, this becomes the command
+	runner.Init()
+
+	// Subcommands work on recordings, and have their own flags
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			subcommand(os.Args[2:])
+			return
+		}
+	}
 	fmt.Println("⭐️ Filesystem Recorder (fs-record)")
 
 	mountPoint := flag.String("mount-path", "", "Mount path (for control from calling process)")
 	outfile := flag.String("out", "", "Output file to write events")
 	outdir := flag.String("out-dir", "", "Output directory to write events")
+	sessionDir := flag.String("session", "", "Session directory to write events, per-pid events, and a manifest of the run (instead of --out)")
+	format := flag.String("format", event.FormatText, "Format of events: "+strings.Join(event.Formats, ", "))
 	readOnly := flag.Bool("read-only", true, "Read only mode (off by default)")
-	mpirun := flag.Bool("mpi", false, "Invoked via MPI, only print for lead process")
+	mpirun := flag.Bool("mpi", false, "Invoked via MPI: name outputs by rank, tag events with their rank, and only print the summary for lead ranks")
+	mpiMount := flag.String("mpi-mount", mountLocal, "With --mpi, each rank mounts its own filesystem (local), or the lead rank on each node mounts one at --mount-path that the others share (shared)")
+	ranksPerNode := flag.Int("ranks-per-node", 0, "With --mpi-mount shared, the number of ranks on each node (0 to find it from the launcher)")
 	mount := flag.Bool("mount", false, "Mount only, intended to be run in background")
+	pids := flag.String("pid", "", "Only record events from these processes and their children (comma separated)")
+	uids := flag.String("uid", "", "Only record events from these users (comma separated)")
+	comms := flag.String("comm", "", "Only record events from these command names (comma separated)")
+	top := flag.Int("top", 10, "Number of most accessed paths to show in the summary at exit")
+	runWith := flag.String("runner", runner.Auto, "How to run the command in the mount: "+strings.Join(runner.Runners, ", ")+" (unshare, falling back to proot)")
+	streamHost := flag.String("stream", "", "Address (host:port) to stream events to subscribers (unset to disable)")
+	events := flag.String("events", strings.Join(event.DefaultTypes, ","), "Event types to record (comma separated, or all): "+strings.Join(event.Types, ", "))
 
 	flag.Parse()
 	args := flag.Args()
@@ -60,10 +138,47 @@ func main() {
 	usingMPI := *mpirun
 	mountOnly := *mount
 
-	// We should eventually figure out how to do this once
+	if !slices.Contains(mpiMounts, *mpiMount) {
+		log.Fatalf("unknown mpi mount %s, choices are %s", *mpiMount, strings.Join(mpiMounts, ", "))
+	}
+
+	// Under MPI, ranks sharing a mount have a lead that records for them
+	rank := ""
+	lead := true
+	localRanks := 0
+	var shared *mpi.Shared
 	if usingMPI {
-		rank := getRank()
-		fmt.Printf("Found rank %s\n", rank)
+		rank = mpi.Rank()
+		if rank == "" {
+			log.Fatal("--mpi is set, but no MPI rank was found in the environment")
+		}
+		if *mpiMount == mountShared {
+			if mountPath == "" || mountOnly {
+				log.Fatal("--mpi-mount shared needs a --mount-path that the ranks on a node share, and cannot be used with --mount")
+			}
+			shared = mpi.NewShared(mountPath)
+			lead = mpi.LocalRank() == "0"
+			localRanks = *ranksPerNode
+			if localRanks == 0 {
+				localRanks = mpi.LocalSize()
+			}
+			if lead && localRanks == 0 {
+				log.Fatal("cannot find the number of ranks on this node, set --ranks-per-node")
+			}
+		}
+		switch {
+		case shared == nil:
+			fmt.Printf("MPI rank %s recording with its own mount\n", rank)
+		case lead:
+			fmt.Printf("MPI rank %s recording for the %d ranks on this node at %s\n", rank, localRanks, mountPath)
+		default:
+			fmt.Printf("MPI rank %s running in the mount of the lead rank at %s\n", rank, mountPath)
+		}
+	}
+
+	// Other ranks on the node run in the mount of the lead rank
+	if !lead {
+		os.Exit(runShared(shared, *runWith, mountPath, rank, args))
 	}
 
 	// Get the full path of the command
@@ -77,49 +192,156 @@ func main() {
 		args[0] = path
 	}
 
-	// We require a recording file for the recorder
-	if *outfile == "" {
-		*outfile = logger.GetEventFile(*outdir)
+	if !slices.Contains(runner.Runners, *runWith) {
+		log.Fatalf("unknown runner %s, choices are %s", *runWith, strings.Join(runner.Runners, ", "))
+	}
+	if !slices.Contains(event.Formats, *format) {
+		log.Fatalf("unknown format %s, choices are %s", *format, strings.Join(event.Formats, ", "))
+	}
+
+	// Only rank 0 (or the lead on each node, with a shared mount) prints
+	printing := rank == "" || rank == "0" || shared != nil
+
+	// Events are saved to a session directory, or a single file, named
+	// by rank under MPI
+	if rank != "" {
+		if *sessionDir != "" {
+			*sessionDir = filepath.Join(*sessionDir, "rank-"+rank)
+		}
+		if *outfile != "" {
+			*outfile = mpi.RankPath(*outfile, rank)
+		}
+	}
+	var recorder logger.EventLogger
+	var recording *session.Session
+	if *sessionDir != "" {
+		if *outfile != "" {
+			log.Fatal("--session and --out cannot be used together")
+		}
+		var err error
+		recording, err = session.New(*sessionDir, *format)
+		if err != nil {
+			log.Fatalf("cannot create session: %s", err)
+		}
+		if !mountOnly {
+			recording.SetCommand(args)
+		}
+		recorder = recording
+	} else {
+		if *outfile == "" {
+			*outfile = logger.GetEventFile(*outdir, rank)
+		}
+		file, err := logger.NewLogger(*outfile, *format)
+		if err != nil {
+			log.Fatalf("cannot open %s: %s", *outfile, err)
+		}
+		recorder = file
+	}
+	filter, err := newFilter(*pids, *uids, *comms)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mask, err := event.ParseMask(*events)
+	if err != nil {
+		log.Fatal(err)
 	}
+
 	// Generate the fusefs server
-	rfs, err := fs.NewRecordFS(mountPath, *outfile, *readOnly)
+	options := fs.Options{Filter: filter, Events: mask, Logger: recorder, Rank: rank}
+	rfs, err := fs.NewRecordFS(mountPath, *readOnly, options)
 	if err != nil {
 		fmt.Println(err)
 		log.Panic("cannot generate fuse server")
 	}
 
+	// The other ranks on the node can use the mount
+	if shared != nil {
+		err = shared.Ready()
+		if err != nil {
+			fmt.Println(err)
+			log.Panic("cannot tell the other ranks the mount is ready")
+		}
+		defer shared.Remove()
+	}
+
+	// Stream events to subscribers while the command runs
+	if *streamHost != "" {
+		server := stream.NewServer(rfs.Stream, 0)
+		err = server.Start(*streamHost)
+		if err != nil {
+			fmt.Println(err)
+			log.Panic("cannot start event stream")
+		}
+		defer server.Stop()
+	}
+
 	// If we are only mounting, wait for something to kill us.
 	if mountOnly {
 		rfs.Server.Wait()
+		if recording != nil {
+			recording.End()
+		}
+		recorder.Close()
+		printSummary(rfs, printing, *top)
 
 	} else {
+		stopped := make(chan struct{})
 
 		// Removes mount point directory when done
 		// Also fixes permission of file
 		defer rfs.Cleanup()
 
-		c := make(chan os.Signal)
+		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
+			close(stopped)
 			rfs.Server.Unmount()
 		}()
 
-		// Execute the command with proot
-		proot := []string{"proot", "-S", rfs.MountPoint, "-0"}
-		args = append(proot, args...)
-		call := strings.Join(args, " ")
-		fmt.Println(call)
-		err = rfs.RunCommand(call)
+		// Execute the command with the mount as its root
+		used, err := runner.Run(*runWith, rfs.MountPoint, args)
+		fmt.Printf("Command was run with %s\n", used)
+
+		// Keep the mount until the other ranks on the node are done
+		if shared != nil {
+			fmt.Printf("Waiting for the other %d ranks on this node\n", localRanks-1)
+			failed, waitErr := shared.WaitDone(localRanks-1, doneTimeout, stopped)
+			if waitErr != nil {
+				fmt.Printf("Warning: %s, unmounting\n", waitErr)
+			}
+			if len(failed) > 0 {
+				fmt.Printf("Warning: ranks %s on this node failed\n", strings.Join(failed, ", "))
+			}
+		}
 
 		// Record the end of command event.
-		logger.LogEvent("Complete", logger.Outfile)
+		complete := &event.Event{Type: event.Complete, Path: recorder.Path(), Rank: rank}
+		recorder.LogEvent(complete)
+		rfs.Stream.Publish(complete)
+		if recording != nil {
+			recording.Finish(runner.ExitCode(err))
+		}
+		recorder.Close()
+		printSummary(rfs, printing, *top)
+
+		// Unmount before the mount point is cleaned up, even on failure
+		rfs.Server.Unmount()
 		if err != nil {
 			fmt.Println(err)
 			log.Panic("error running command")
 		}
-		// Unlike compat, explicitly close after command is done running
 		fmt.Println("Command is done running")
-		rfs.Server.Unmount()
 	}
 }
+
+// printSummary prints the counts, failures and latency of recorded events.
+// Under MPI, only rank 0 prints, or the lead rank on each node when ranks
+// share a mount.
+func printSummary(rfs *fs.RecordFS, printing bool, top int) {
+	if !printing {
+		return
+	}
+	fmt.Println("Summary of recorded events:")
+	rfs.Summary.Write(os.Stdout, top)
+}
@@ -5,7 +5,13 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/compspec/compat-lib/pkg/oras"
 	"github.com/compspec/compat-lib/pkg/server"
 )
 
@@ -14,15 +20,75 @@ const (
 )
 
 var (
-	host string
+	host      string
+	prefixes  string
+	mediaType string
+	platform  string
+	cache     string
+	cacheTTL  time.Duration
+	cacheSize int64
+
+	// Registry connection
+	plainHttp      bool
+	registryConfig string
+	caFile         string
+
+	// Signature verification
+	publicKey string
+	strict    bool
 )
 
 func main() {
 	flag.StringVar(&host, "host", ":50051", "Server address (host:port)")
+	flag.StringVar(&prefixes, "prefix", "", "Extra library prefixes to search, comma separated (e.g., spack or module trees)")
+	flag.StringVar(&mediaType, "media-type", oras.CompatibilityMediaType, "Media type of the compatibility layer for artifacts requested by uri")
+	flag.StringVar(&platform, "platform", "", "Platform (os/arch[/variant]) to select from a multi-platform artifact (defaults to the host)")
+	flag.StringVar(&cache, "cache", "", "Cache directory for artifacts requested by uri (unset to disable)")
+	flag.DurationVar(&cacheTTL, "cache-ttl", 5*time.Minute, "How long a cached tag is trusted before revalidating with the registry")
+	flag.Int64Var(&cacheSize, "cache-max-size", 0, "Maximum size of the cache in bytes, least recently used evicted first (0 is unlimited)")
+	flag.BoolVar(&plainHttp, "plain-http", false, "Use plain http to connect to registries (e.g., a local registry)")
+	flag.StringVar(&registryConfig, "registry-config", "", "Docker config.json with registry credentials (defaults to the docker config)")
+	flag.StringVar(&caFile, "ca-file", "", "PEM bundle of certificate authorities to trust for registries")
+	flag.StringVar(&publicKey, "public-key", "", "PEM public key to verify cosign signatures of artifacts requested by uri")
+	flag.BoolVar(&strict, "strict", false, "Refuse artifacts that cannot be verified with the public key")
 	flag.Parse()
 
-	s := server.NewServer(serverName)
+	options := server.Options{
+		MediaType: mediaType,
+		Platform:  platform,
+		PublicKey: publicKey,
+		Strict:    strict,
+		Cache:     cache,
+		CacheOptions: oras.CacheOptions{
+			TTL:     cacheTTL,
+			MaxSize: cacheSize,
+		},
+		Registry: oras.RegistryOptions{
+			PlainHTTP:  plainHttp,
+			ConfigPath: registryConfig,
+			CAFile:     caFile,
+		},
+	}
+	if prefixes != "" {
+		options.Prefixes = strings.Split(prefixes, ",")
+	}
+	s, err := server.NewServer(serverName, options)
+	if err != nil {
+		fmt.Println(err)
+		log.Fatal("error creating compatibility server")
+	}
 	log.Printf("🧩 starting compatibility server: %s", s.String())
+
+	// Send SIGHUP to refresh the library inventory
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			if err := s.Refresh(); err != nil {
+				log.Printf("error refreshing library inventory: %s", err)
+			}
+		}
+	}()
 	if err := s.Start(context.Background(), host); err != nil {
 		fmt.Println(err)
 		log.Fatal("error while running compatibility server")
@@ -9,7 +9,10 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/compspec/compat-lib/pkg/event"
 	fs "github.com/compspec/compat-lib/pkg/fs/slim"
+	"github.com/compspec/compat-lib/pkg/logger"
+	"github.com/compspec/compat-lib/pkg/stream"
 	"github.com/compspec/compat-lib/pkg/utils"
 )
 
@@ -25,6 +28,7 @@ func main() {
 	verbose := flag.Bool("v", false, "Run proot in verbose mode (off by default)")
 	outfile := flag.String("out", "", "Output file to write events (unset will not write anything anywhere)")
 	keepCache := flag.Bool("keep", false, "Do not cleanup the cache")
+	streamHost := flag.String("stream", "", "Address (host:port) to stream events to subscribers (unset to disable)")
 
 	flag.Parse()
 	args := flag.Args()
@@ -42,11 +46,33 @@ func main() {
 	}
 
 	// Generate the fusefs server
-	sfs, err := fs.NewSlimFS(mountPath, *outfile, *readOnly)
+	// Events are only saved with an output file
+	var events logger.EventLogger
+	if *outfile != "" {
+		file, err := logger.NewLogger(*outfile, event.FormatText)
+		if err != nil {
+			fmt.Println(err)
+			log.Panicf("Cannot open output file")
+		}
+		defer file.Close()
+		events = file
+	}
+	sfs, err := fs.NewSlimFS(mountPath, events, *readOnly)
 	if err != nil {
 		fmt.Println(err)
 		log.Panicf("Cannot generate fuse server")
 	}
+
+	// Stream events to subscribers while the command runs
+	if *streamHost != "" {
+		server := stream.NewServer(sfs.Stream, 0)
+		err = server.Start(*streamHost)
+		if err != nil {
+			fmt.Println(err)
+			log.Panicf("Cannot start event stream")
+		}
+		defer server.Stop()
+	}
 	fmt.Println("Mounted!")
 	fmt.Printf("   ReadOnly: %t\n", *readOnly)
 	fmt.Printf("    Verbose: %t\n", *verbose)
@@ -73,7 +99,7 @@ func main() {
 		}
 	}
 
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
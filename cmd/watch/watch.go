@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	"github.com/compspec/compat-lib/pkg/stream"
+	pb "github.com/compspec/compat-lib/protos"
+)
+
+// Formats that can be printed (binary needs a file)
+var formats = []string{event.FormatText, event.FormatJSON}
+
+func main() {
+	host := flag.String("host", ":50052", "Address (host:port) of the event stream (fs-record --stream)")
+	types := flag.String("events", "", "Event types to receive (comma separated, unset for all): "+strings.Join(event.Types, ", "))
+	buffer := flag.Int("buffer", 0, "Events buffered for this subscriber before they are dropped (0 for the server default)")
+	format := flag.String("format", event.FormatJSON, "Format to print events: "+strings.Join(formats, ", "))
+	flag.Parse()
+
+	if !slices.Contains(formats, *format) {
+		log.Fatalf("unknown format %s, choices are %s", *format, strings.Join(formats, ", "))
+	}
+	writer, err := event.NewWriter(os.Stdout, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	request := &pb.StreamRequest{Buffer: int32(*buffer)}
+	if *types != "" {
+		request.Types = strings.Split(*types, ",")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	// Drops are reported on stderr so stdout stays parseable
+	total := uint64(0)
+	err = stream.Subscribe(ctx, *host, request, func(e *event.Event, dropped uint64) error {
+		if dropped > 0 {
+			total += dropped
+			fmt.Fprintf(os.Stderr, "dropped %d events (%d total)\n", dropped, total)
+		}
+		return writer.Write(e)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+}
@@ -5,9 +5,11 @@ go 1.22
 require (
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 	github.com/hanwen/go-fuse/v2 v2.6.1
+	github.com/opencontainers/go-digest v1.0.0
 	github.com/opencontainers/image-spec v1.1.0
 	github.com/pkg/errors v0.9.1
 	github.com/u-root/u-root v0.14.0
+	golang.org/x/sys v0.24.0
 	google.golang.org/grpc v1.67.1
 	google.golang.org/protobuf v1.35.1
 	oras.land/oras-go/v2 v2.5.0
@@ -15,10 +17,8 @@ require (
 )
 
 require (
-	github.com/opencontainers/go-digest v1.0.0 // indirect
 	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sync v0.8.0 // indirect
-	golang.org/x/sys v0.24.0 // indirect
 	golang.org/x/text v0.17.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240814211410-ddb44dafa142 // indirect
 )
@@ -2,9 +2,10 @@ package client
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"os"
 
+	"github.com/compspec/compat-lib/pkg/utils"
 	pb "github.com/compspec/compat-lib/protos"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -24,9 +25,10 @@ var _ Client = (*CompatClient)(nil)
 // Client interface defines functions required for a valid client
 type Client interface {
 	CheckCompatibility(ctx context.Context, tocheck string) (*pb.Response, error)
+	Close() error
 }
 
-// NewClient creates a new RainbowClient
+// NewClient creates a new CompatClient
 func NewClient(host string) (Client, error) {
 	if host == "" {
 		return nil, errors.New("host is required")
@@ -42,11 +44,9 @@ func NewClient(host string) (Client, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to connect to %s", host)
 	}
-	defer conn.Close()
-
 	c.connection = conn
 	c.service = pb.NewCompatibilityServiceClient(conn)
-	return c, nil
+	return &c, nil
 }
 
 // Close closes the created resources (e.g. connection).
@@ -69,9 +69,25 @@ func (c *CompatClient) GetHost() string {
 
 // Check compatibility of an artifact against the known service database
 // toCheck can be either a URI (to download from a registry) or the path to
-// a json file. Right now I'm assuming the json path.
-func (c CompatClient) CheckCompatibility(ctx context.Context, tocheck string) (*pb.Response, error) {
-	fmt.Println(tocheck)
-	response := &pb.Response{}
+// a json file. If the path exists, we assume a json file.
+func (c *CompatClient) CheckCompatibility(ctx context.Context, tocheck string) (*pb.Response, error) {
+	request := &pb.CompatRequest{}
+	exists, err := utils.PathExists(tocheck)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		payload, err := os.ReadFile(tocheck)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read %s", tocheck)
+		}
+		request.Payload = string(payload)
+	} else {
+		request.Uri = tocheck
+	}
+	response, err := c.service.CheckCompatibility(ctx, request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot check compatibility of %s", tocheck)
+	}
 	return response, nil
 }
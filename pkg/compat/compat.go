@@ -1,23 +1,64 @@
 package compat
 
 import (
-	"fmt"
 	"path/filepath"
+	"sort"
 )
 
+// BinaryProperties are ISA, ABI, and OS properties from an ELF header,
+// which are recorded in the executable and hardware of a spec
+type BinaryProperties struct {
+	Machine     string
+	Class       string
+	Endianness  string
+	OSABI       string
+	Interpreter string
+
+	// Minimum kernel version from the .note.ABI-tag (if present)
+	MinKernel string
+}
+
 // GenerateLibraryArtifact generates an artifact to describe a library of interest
 // We will want to use this to determine if a system can support running an application
-func GenerateLibraryArtifact(name string, libs []string) *CompatibiitySpec {
+// needs are symbol versions required from each library (soname), and props
+// are properties of the binary (ISA, ABI, OS) from the ELF header.
+func GenerateLibraryArtifact(
+	name string,
+	libs []string,
+	needs map[string][]string,
+	props *BinaryProperties,
+) *CompatibiitySpec {
 
 	// Ensure we have the basename
 	basename := filepath.Base(name) //use this built-in function to obtain filename
 
 	// Generate the compatibility spec
 	artifact := NewCompatibilitySpec()
-	artifact.AddAttribute("llnl.compatlib.executable-name", basename)
-	for i, lib := range libs {
-		key := fmt.Sprintf("llnl.compatlib.library-name.%d", i)
-		artifact.AddAttribute(key, lib)
+	artifact.Executable.Name = basename
+	if props != nil {
+		artifact.Executable.Interpreter = props.Interpreter
+		artifact.Executable.OSABI = props.OSABI
+		artifact.Executable.MinKernel = props.MinKernel
+		artifact.Hardware = Hardware{
+			Machine:    props.Machine,
+			Class:      props.Class,
+			Endianness: props.Endianness,
+		}
+	}
+	for _, lib := range libs {
+		artifact.AddLibrary(lib)
+	}
+
+	// Sort libraries so the order is consistent between runs
+	libraries := []string{}
+	for lib := range needs {
+		libraries = append(libraries, lib)
+	}
+	sort.Strings(libraries)
+	for _, lib := range libraries {
+		for _, version := range needs[lib] {
+			artifact.AddSymbol(lib, version)
+		}
 	}
 	return artifact
 }
@@ -0,0 +1,122 @@
+package compat
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys (and prefixes for lists) of the legacy flat attribute form, e.g.,
+// llnl.compatlib.library-name.0. Version needed values are <soname>:<version>.
+const (
+	ExecutableNameKey   = "llnl.compatlib.executable-name"
+	LibraryNamePrefix   = "llnl.compatlib.library-name."
+	VersionNeededPrefix = "llnl.compatlib.version-needed."
+	VersionSeparator    = ":"
+	MachineKey          = "llnl.compatlib.machine"
+	ClassKey            = "llnl.compatlib.class"
+	EndiannessKey       = "llnl.compatlib.endianness"
+	OSABIKey            = "llnl.compatlib.os-abi"
+	InterpreterKey      = "llnl.compatlib.interpreter"
+	MinKernelKey        = "llnl.compatlib.min-kernel"
+)
+
+// Keys of the legacy form that are not lists
+var legacyKeys = map[string]bool{
+	ExecutableNameKey: true,
+	InterpreterKey:    true,
+	OSABIKey:          true,
+	MinKernelKey:      true,
+	MachineKey:        true,
+	ClassKey:          true,
+	EndiannessKey:     true,
+}
+
+// IsLegacy determines if a spec uses the flat attribute form: it has an
+// older version, no typed sections, and any legacy attribute (which might
+// not include the executable name)
+func (s *CompatibiitySpec) IsLegacy() bool {
+	if slices.Contains(supportedVersions, s.Version) || s.Executable.Name != "" || len(s.Libraries) > 0 || len(s.Symbols) > 0 {
+		return false
+	}
+	for key := range s.Attributes {
+		if legacyKeys[key] || isListKey(key, LibraryNamePrefix) || isListKey(key, VersionNeededPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Migrate converts the legacy flat attributes into typed sections.
+// Attributes that are not known are kept. A spec without an executable
+// name cannot be migrated, since the typed form requires one.
+func (s *CompatibiitySpec) Migrate() error {
+	attrs := s.Attributes
+	if attrs[ExecutableNameKey] == "" {
+		return fmt.Errorf("cannot migrate a legacy spec without %s", ExecutableNameKey)
+	}
+	s.Version = SpecVersion
+	s.Attributes = Attributes{}
+
+	s.Executable = Executable{
+		Name:        attrs[ExecutableNameKey],
+		Interpreter: attrs[InterpreterKey],
+		OSABI:       attrs[OSABIKey],
+		MinKernel:   attrs[MinKernelKey],
+	}
+	s.Hardware = Hardware{
+		Machine:    attrs[MachineKey],
+		Class:      attrs[ClassKey],
+		Endianness: attrs[EndiannessKey],
+	}
+	s.Libraries = []Library{}
+	for _, soname := range listAttribute(attrs, LibraryNamePrefix) {
+		s.AddLibrary(soname)
+	}
+	s.Symbols = []Symbol{}
+	for _, value := range listAttribute(attrs, VersionNeededPrefix) {
+		lib, version, ok := strings.Cut(value, VersionSeparator)
+		if ok {
+			s.AddSymbol(lib, version)
+		}
+	}
+	for key, value := range attrs {
+		if legacyKeys[key] || isListKey(key, LibraryNamePrefix) || isListKey(key, VersionNeededPrefix) {
+			continue
+		}
+		s.AddAttribute(key, value)
+	}
+	return nil
+}
+
+// listAttribute returns values for index-suffixed keys with a prefix
+// Keys that do not end in an integer index are ignored.
+func listAttribute(attrs Attributes, prefix string) []string {
+	indices := []int{}
+	values := map[int]string{}
+	for key, value := range attrs {
+		if !isListKey(key, prefix) {
+			continue
+		}
+		index, _ := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		indices = append(indices, index)
+		values[index] = value
+	}
+	sort.Ints(indices)
+	items := []string{}
+	for _, index := range indices {
+		items = append(items, values[index])
+	}
+	return items
+}
+
+// isListKey determines if a key is a prefix with an integer index
+func isListKey(key, prefix string) bool {
+	if !strings.HasPrefix(key, prefix) {
+		return false
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+	return err == nil
+}
@@ -0,0 +1,92 @@
+package compat
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsLegacy(t *testing.T) {
+	tests := []struct {
+		name string
+		spec CompatibiitySpec
+		want bool
+	}{
+		{"legacy", CompatibiitySpec{Version: "0.0.1", Attributes: Attributes{ExecutableNameKey: "xz", LibraryNamePrefix + "0": "libc.so.6"}}, true},
+		{"legacy without executable name", CompatibiitySpec{Version: "0.0.1", Attributes: Attributes{LibraryNamePrefix + "0": "libc.so.6"}}, true},
+		{"legacy without version", CompatibiitySpec{Attributes: Attributes{MachineKey: "x86_64"}}, true},
+		{"current version", CompatibiitySpec{Version: SpecVersion, Attributes: Attributes{ExecutableNameKey: "xz"}}, false},
+		{"typed sections", CompatibiitySpec{Version: "0.0.1", Executable: Executable{Name: "xz"}, Attributes: Attributes{ExecutableNameKey: "xz"}}, false},
+		{"other attributes only", CompatibiitySpec{Version: "0.0.1", Attributes: Attributes{"org.example.owner": "me"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.spec.IsLegacy(); got != test.want {
+				t.Errorf("IsLegacy() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		libraries []Library
+		symbols   []Symbol
+	}{
+		{
+			name:      "legacy",
+			data:      `{"version": "0.0.1", "attributes": {"llnl.compatlib.executable-name": "xz", "llnl.compatlib.library-name.1": "liblzma.so.5", "llnl.compatlib.library-name.0": "libc.so.6", "llnl.compatlib.version-needed.0": "libc.so.6:GLIBC_2.34"}}`,
+			libraries: []Library{{Soname: "libc.so.6"}, {Soname: "liblzma.so.5"}},
+			symbols:   []Symbol{{Library: "libc.so.6", Version: "GLIBC_2.34"}},
+		},
+		{
+			name:      "current",
+			data:      `{"version": "0.1.0", "executable": {"name": "xz"}, "libraries": [{"soname": "libc.so.6"}], "symbols": []}`,
+			libraries: []Library{{Soname: "libc.so.6"}},
+			symbols:   []Symbol{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := Load([]byte(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec.Libraries, test.libraries) {
+				t.Errorf("Load() libraries = %v, want %v", spec.Libraries, test.libraries)
+			}
+			if !reflect.DeepEqual(spec.Symbols, test.symbols) {
+				t.Errorf("Load() symbols = %v, want %v", spec.Symbols, test.symbols)
+			}
+		})
+	}
+}
+
+// A legacy spec that cannot be migrated says so, rather than failing to
+// validate as the typed spec it would become
+func TestLoadLegacyWithoutName(t *testing.T) {
+	_, err := Load([]byte(`{"version": "0.0.1", "attributes": {"llnl.compatlib.library-name.0": "libc.so.6"}}`))
+	if err == nil || !strings.Contains(err.Error(), "cannot migrate a legacy spec without "+ExecutableNameKey) {
+		t.Errorf("Load() error = %v, want a migration error", err)
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	spec, err := LoadFile(filepath.Join("..", "..", "example", "compat", "xz-libs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Version != SpecVersion || spec.Executable.Name != "xz" {
+		t.Errorf("LoadFile() = version %s for %q, want %s for xz", spec.Version, spec.Executable.Name, SpecVersion)
+	}
+	libraries := []Library{{Soname: "libc.so.6"}, {Soname: "ld-linux-x86-64.so.2"}, {Soname: "liblzma.so.5"}}
+	if !reflect.DeepEqual(spec.Libraries, libraries) {
+		t.Errorf("LoadFile() libraries = %v, want %v", spec.Libraries, libraries)
+	}
+	if len(spec.Attributes) != 0 {
+		t.Errorf("LoadFile() kept legacy attributes %v", spec.Attributes)
+	}
+}
@@ -0,0 +1,47 @@
+package compat
+
+import (
+	"encoding/json"
+)
+
+// A CompatibilityResult is the outcome of checking a spec against a host.
+// It is returned as the payload of the server response.
+type CompatibilityResult struct {
+	Compatible bool `json:"compatible"`
+
+	// Incompatible are reasons the binary (ISA, ABI, OS) cannot run on the host
+	Incompatible []string `json:"incompatible"`
+
+	// Found libraries (soname) and the paths they resolve to on the host,
+	// the first of which defines the symbol versions needed (if any does)
+	Found map[string][]string `json:"found"`
+
+	// Missing are sonames that cannot be resolved on the host
+	Missing []string `json:"missing"`
+
+	// MissingVersions are symbol versions (by soname) not defined
+	// by the library that resolves on the host
+	MissingVersions map[string][]string `json:"missing_versions"`
+
+	// Message is provided when the check could not be done
+	Message string `json:"message,omitempty"`
+}
+
+// NewCompatibilityResult returns an empty result
+func NewCompatibilityResult() *CompatibilityResult {
+	return &CompatibilityResult{
+		Incompatible:    []string{},
+		Found:           map[string][]string{},
+		Missing:         []string{},
+		MissingVersions: map[string][]string{},
+	}
+}
+
+// ToJson dumps the result to json for the response payload
+func (r *CompatibilityResult) ToJson() ([]byte, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return []byte{}, err
+	}
+	return b, err
+}
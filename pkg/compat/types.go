@@ -2,32 +2,117 @@ package compat
 
 import (
 	"encoding/json"
+	"os"
+)
 
-	"github.com/compspec/compat-lib/pkg/version"
+const (
+	// SpecVersion is the version of the schema for the compatibility spec
+	SpecVersion = "0.1.0"
 )
 
 // NewCompatibilitySpec returns a new compatibility spec
 func NewCompatibilitySpec() *CompatibiitySpec {
-	spec := CompatibiitySpec{Version: version.Version}
-	spec.Attributes = Attributes{}
+	spec := CompatibiitySpec{Version: SpecVersion}
+	spec.Libraries = []Library{}
+	spec.Symbols = []Symbol{}
 	return &spec
 }
 
-// A compatibility spec describes an application
-// Attributes are key value pairs, namespace TBA
-// Let's keep it simple like that.
+// A compatibility spec describes an application, with typed sections
+// for the executable, the hardware it targets, and the libraries and
+// symbol versions it needs. Attributes are for any other (namespaced)
+// metadata.
 type CompatibiitySpec struct {
 	Version    string     `json:"version"`
-	Attributes Attributes `json:"attributes"`
+	Executable Executable `json:"executable"`
+	Hardware   Hardware   `json:"hardware"`
+	Libraries  []Library  `json:"libraries"`
+	Symbols    []Symbol   `json:"symbols"`
+	Image      *Image     `json:"image,omitempty"`
+	Attributes Attributes `json:"attributes,omitempty"`
+}
+
+// Image describes a container (rootfs) with many executables
+type Image struct {
+	Reference   string   `json:"reference,omitempty"`
+	Executables []string `json:"executables"`
+}
+
+// Executable describes the binary and the OS it targets
+type Executable struct {
+	Name        string `json:"name"`
+	Interpreter string `json:"interpreter,omitempty"`
+	OSABI       string `json:"os_abi,omitempty"`
+	MinKernel   string `json:"min_kernel,omitempty"`
+}
+
+// Hardware describes the ISA the binary is built for
+type Hardware struct {
+	Machine    string `json:"machine,omitempty"`
+	Class      string `json:"class,omitempty"`
+	Endianness string `json:"endianness,omitempty"`
+}
+
+// Library is a shared library needed by the binary. Bundled libraries
+// are provided inside a container image (at the path), and others are
+// expected from the host.
+type Library struct {
+	Soname  string `json:"soname"`
+	Bundled bool   `json:"bundled,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// Symbol is a symbol version needed from a library, e.g., GLIBC_2.34
+type Symbol struct {
+	Library string `json:"library"`
+	Version string `json:"version"`
 }
 
 type Attributes map[string]string
 
 // AddAttributes adds attributes to the artifact
 func (s *CompatibiitySpec) AddAttribute(key, value string) {
+	if s.Attributes == nil {
+		s.Attributes = Attributes{}
+	}
 	s.Attributes[key] = value
 }
 
+// AddLibrary adds a library needed by the binary
+func (s *CompatibiitySpec) AddLibrary(soname string) {
+	s.Libraries = append(s.Libraries, Library{Soname: soname})
+}
+
+// AddSymbol adds a symbol version needed from a library
+func (s *CompatibiitySpec) AddSymbol(library, version string) {
+	s.Symbols = append(s.Symbols, Symbol{Library: library, Version: version})
+}
+
+// AddBundledLibrary adds a library provided inside a container image
+func (s *CompatibiitySpec) AddBundledLibrary(soname, path string) {
+	s.Libraries = append(s.Libraries, Library{Soname: soname, Bundled: true, Path: path})
+}
+
+// Sonames returns the sonames of needed libraries expected from the host
+func (s *CompatibiitySpec) Sonames() []string {
+	sonames := []string{}
+	for _, lib := range s.Libraries {
+		if !lib.Bundled {
+			sonames = append(sonames, lib.Soname)
+		}
+	}
+	return sonames
+}
+
+// VersionNeeds returns the symbol versions required from each library
+func (s *CompatibiitySpec) VersionNeeds() map[string][]string {
+	needs := map[string][]string{}
+	for _, symbol := range s.Symbols {
+		needs[symbol.Library] = append(needs[symbol.Library], symbol.Version)
+	}
+	return needs
+}
+
 // ToJson dumps our request to json for the artifact
 func (r *CompatibiitySpec) ToJson() ([]byte, error) {
 	b, err := json.MarshalIndent(r, "", "  ")
@@ -36,3 +121,29 @@ func (r *CompatibiitySpec) ToJson() ([]byte, error) {
 	}
 	return b, err
 }
+
+// Load reads a compatibility spec from json, migrating the legacy
+// flat attribute form if needed, and validates it
+func Load(data []byte) (*CompatibiitySpec, error) {
+	spec := CompatibiitySpec{}
+	err := json.Unmarshal(data, &spec)
+	if err != nil {
+		return nil, err
+	}
+	if spec.IsLegacy() {
+		err = spec.Migrate()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &spec, spec.Validate()
+}
+
+// LoadFile reads a compatibility spec from a json file
+func LoadFile(path string) (*CompatibiitySpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(data)
+}
@@ -0,0 +1,201 @@
+package compat
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The JSON Schema of a spec, which Validate checks it against
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+var (
+	schema = mustSchema(schemaJSON)
+
+	// Versions of the spec we know how to read
+	supportedVersions = schema.Properties["version"].Enum
+)
+
+// A jsonSchema is the part of JSON Schema that schema.json uses
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []string               `json:"enum"`
+	Pattern              string                 `json:"pattern"`
+	MinLength            int                    `json:"minLength"`
+
+	pattern    *regexp.Regexp
+	additional *jsonSchema
+}
+
+// schemaTypes are the types a value can have, a string or list in the schema
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// mustSchema parses a schema and compiles its patterns
+func mustSchema(data []byte) *jsonSchema {
+	s := jsonSchema{}
+	err := json.Unmarshal(data, &s)
+	if err == nil {
+		err = s.compile()
+	}
+	if err != nil {
+		panic(fmt.Sprintf("invalid schema.json: %s", err))
+	}
+	return &s
+}
+
+// compile compiles the patterns of a schema and the schemas under it.
+// Additional properties are allowed (true), not allowed (false), or have
+// a schema.
+func (s *jsonSchema) compile() error {
+	var err error
+	if s.Pattern != "" {
+		s.pattern, err = regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+	}
+	if len(s.AdditionalProperties) > 0 && s.AdditionalProperties[0] == '{' {
+		s.additional = &jsonSchema{}
+		err = json.Unmarshal(s.AdditionalProperties, s.additional)
+		if err == nil {
+			err = s.additional.compile()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, property := range s.Properties {
+		err = property.compile()
+		if err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+	return nil
+}
+
+// Validate checks the spec against its JSON Schema (schema.json), and that
+// no library is listed twice, returning all errors found
+func (s *CompatibiitySpec) Validate() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	errs := schema.validate("", value)
+
+	seen := map[string]bool{}
+	for _, lib := range s.Libraries {
+		if seen[lib.Soname] {
+			errs = append(errs, fmt.Errorf("library %s is listed more than once", lib.Soname))
+		}
+		seen[lib.Soname] = true
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks a value (decoded from json) at a path of the spec
+func (s *jsonSchema) validate(path string, value any) []error {
+	if len(s.Type) > 0 && !slices.Contains(s.Type, jsonType(value)) {
+		return []error{fmt.Errorf("%s must be %s", path, strings.Join(s.Type, " or "))}
+	}
+	errs := []error{}
+	switch value := value.(type) {
+	case string:
+		if len(value) < s.MinLength {
+			if value == "" {
+				return []error{fmt.Errorf("%s is required", path)}
+			}
+			errs = append(errs, fmt.Errorf("%s %q is shorter than %d", path, value, s.MinLength))
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, value) {
+			errs = append(errs, fmt.Errorf("%s %q must be one of %s", path, value, strings.Join(s.Enum, ", ")))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			errs = append(errs, fmt.Errorf("%s %q does not match %s", path, value, s.Pattern))
+		}
+
+	case []any:
+		if s.Items != nil {
+			for i, item := range value {
+				errs = append(errs, s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item)...)
+			}
+		}
+
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				errs = append(errs, fmt.Errorf("%s is required", field(path, name)))
+			}
+		}
+		names := []string{}
+		for name := range value {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				property = s.additional
+			}
+			if !ok && property == nil {
+				if string(s.AdditionalProperties) == "false" {
+					errs = append(errs, fmt.Errorf("%s is not allowed", field(path, name)))
+				}
+				continue
+			}
+			errs = append(errs, property.validate(field(path, name), value[name])...)
+		}
+	}
+	return errs
+}
+
+// field returns the path of a field of an object at a path
+func field(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonType returns the JSON Schema type of a value decoded from json
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
@@ -0,0 +1,87 @@
+package compat
+
+import (
+	"strings"
+	"testing"
+)
+
+// xz is a valid spec to break in each test
+func xz() *CompatibiitySpec {
+	spec := NewCompatibilitySpec()
+	spec.Executable = Executable{Name: "xz", Interpreter: "/lib64/ld-linux-x86-64.so.2", MinKernel: "3.2.0"}
+	spec.Hardware = Hardware{Machine: "x86_64", Class: "ELF64", Endianness: "little"}
+	spec.AddLibrary("libc.so.6")
+	spec.AddBundledLibrary("liblzma.so.5", "/usr/lib/liblzma.so.5")
+	spec.AddSymbol("libc.so.6", "GLIBC_2.34")
+	spec.AddAttribute("org.example.owner", "me")
+	return spec
+}
+
+func TestValidate(t *testing.T) {
+	err := xz().Validate()
+	if err != nil {
+		t.Fatalf("Validate() = %s for a valid spec", err)
+	}
+
+	for name, test := range map[string]struct {
+		change func(spec *CompatibiitySpec)
+		errs   []string
+	}{
+		"unsupported version": {
+			func(spec *CompatibiitySpec) { spec.Version = "9.0.0" },
+			[]string{`version "9.0.0" must be one of 0.1.0`},
+		},
+		"no executable name": {
+			func(spec *CompatibiitySpec) { spec.Executable.Name = "" },
+			[]string{"executable.name is required"},
+		},
+		"kernel": {
+			func(spec *CompatibiitySpec) { spec.Executable.MinKernel = "3.2-rc1" },
+			[]string{`executable.min_kernel "3.2-rc1" does not match`},
+		},
+		"hardware": {
+			func(spec *CompatibiitySpec) { spec.Hardware.Class, spec.Hardware.Endianness = "ELF128", "middle" },
+			[]string{`hardware.class "ELF128" must be one of ELF32, ELF64`, `hardware.endianness "middle" must be one of little, big`},
+		},
+		"soname": {
+			func(spec *CompatibiitySpec) { spec.AddLibrary("/usr/lib/libm.so.6"); spec.AddLibrary("") },
+			[]string{`libraries[2].soname "/usr/lib/libm.so.6" does not match`, "libraries[3].soname is required"},
+		},
+		"library listed twice": {
+			func(spec *CompatibiitySpec) { spec.AddLibrary("libc.so.6") },
+			[]string{"library libc.so.6 is listed more than once"},
+		},
+		"symbol": {
+			func(spec *CompatibiitySpec) { spec.AddSymbol("libm.so.6", "") },
+			[]string{"symbols[1].version is required"},
+		},
+		"image": {
+			func(spec *CompatibiitySpec) { spec.Image = &Image{Reference: "ghcr.io/lammps/lammps"} },
+			nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			spec := xz()
+			test.change(spec)
+			err := spec.Validate()
+			if len(test.errs) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() is valid, want %q", test.errs)
+			}
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(test.errs) {
+				t.Fatalf("Validate() = %q, want %q", lines, test.errs)
+			}
+			for i, line := range lines {
+				if !strings.HasPrefix(line, test.errs[i]) {
+					t.Errorf("Validate() = %q, want %q", line, test.errs[i])
+				}
+			}
+		})
+	}
+}
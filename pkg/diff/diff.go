@@ -0,0 +1,270 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// Options select the events of a recording that are compared
+type Options struct {
+
+	// Event types to compare (nil for all)
+	Events event.Mask
+
+	// Include failed operations (e.g., lookups of missing libraries)
+	Failed bool
+}
+
+// A Recording is the sequence of paths in a file of events, in the order
+// each was first accessed
+type Recording struct {
+	Name   string `json:"name"`
+	Events int    `json:"events"`
+	Paths  int    `json:"paths"`
+
+	order  []string
+	access map[string]*access
+}
+
+// access is how one recording accessed a path
+type access struct {
+	count    int
+	offset   int64
+	duration int64
+}
+
+// A Region is a run of paths accessed in a different order, at a position
+// (counted from 0) in each recording
+type Region struct {
+	A     int      `json:"a"`
+	B     int      `json:"b"`
+	Paths []string `json:"paths"`
+}
+
+// A Delta compares the timing of a path in both recordings. Offsets are
+// from the first event of each recording, and durations are the total of
+// its operations, all in nanoseconds.
+type Delta struct {
+	Path      string `json:"path"`
+	CountA    int    `json:"countA"`
+	CountB    int    `json:"countB"`
+	OffsetA   int64  `json:"offsetA"`
+	OffsetB   int64  `json:"offsetB"`
+	DurationA int64  `json:"durationA"`
+	DurationB int64  `json:"durationB"`
+}
+
+// A Result is the difference between two recordings
+type Result struct {
+	A         *Recording `json:"a"`
+	B         *Recording `json:"b"`
+	Common    int        `json:"common"`
+	InOrder   int        `json:"inOrder"`
+	Removed   []string   `json:"removed"`
+	Added     []string   `json:"added"`
+	Reordered []Region   `json:"reordered"`
+
+	// Timing of paths in both, the largest changes first
+	Timing []Delta `json:"timing"`
+}
+
+// NewRecording collects the paths of events, in the order each was
+// first accessed
+func NewRecording(name string, events []*event.Event, options Options) *Recording {
+	r := &Recording{Name: name, access: map[string]*access{}}
+	start := int64(0)
+	for _, e := range events {
+		if start == 0 || (e.Timestamp != 0 && e.Timestamp < start) {
+			start = e.Timestamp
+		}
+	}
+	for _, e := range events {
+		if e.Type == event.Complete || e.Path == "" || !options.Events.Has(e.Type) {
+			continue
+		}
+		if e.Errno != 0 && !options.Failed {
+			continue
+		}
+		r.Events++
+		a, ok := r.access[e.Path]
+		if !ok {
+			a = &access{offset: e.Timestamp - start}
+			r.access[e.Path] = a
+			r.order = append(r.order, e.Path)
+		}
+		a.count++
+		a.duration += e.Duration
+	}
+	r.Paths = len(r.order)
+	return r
+}
+
+// Compare aligns the paths of two recordings. Paths in both are aligned
+// by their longest common subsequence, and the rest of the common paths
+// are reordered.
+func Compare(a, b *Recording) *Result {
+	result := &Result{A: a, B: b, Removed: []string{}, Added: []string{}, Reordered: []Region{}, Timing: []Delta{}}
+
+	// Positions in b of the paths in both, in the order of a
+	positions := map[string]int{}
+	for i, path := range b.order {
+		positions[path] = i
+	}
+	common := []string{}
+	for _, path := range a.order {
+		if _, ok := b.access[path]; ok {
+			common = append(common, path)
+		} else {
+			result.Removed = append(result.Removed, path)
+		}
+	}
+	for _, path := range b.order {
+		if _, ok := a.access[path]; !ok {
+			result.Added = append(result.Added, path)
+		}
+	}
+	sequence := make([]int, len(common))
+	for i, path := range common {
+		sequence[i] = positions[path]
+	}
+	inOrder := increasing(sequence)
+	result.Common = len(common)
+	for _, marked := range inOrder {
+		if marked {
+			result.InOrder++
+		}
+	}
+
+	// Paths out of order that are next to each other in both are a region
+	indexA := map[string]int{}
+	for i, path := range a.order {
+		indexA[path] = i
+	}
+	var region *Region
+	last := -1
+	for i, path := range common {
+		if inOrder[i] {
+			region = nil
+			continue
+		}
+		if region != nil && sequence[i] == last+1 {
+			region.Paths = append(region.Paths, path)
+		} else {
+			result.Reordered = append(result.Reordered, Region{A: indexA[path], B: sequence[i], Paths: []string{path}})
+			region = &result.Reordered[len(result.Reordered)-1]
+		}
+		last = sequence[i]
+	}
+
+	for _, path := range common {
+		accessA, accessB := a.access[path], b.access[path]
+		result.Timing = append(result.Timing, Delta{
+			Path:      path,
+			CountA:    accessA.count,
+			CountB:    accessB.count,
+			OffsetA:   accessA.offset,
+			OffsetB:   accessB.offset,
+			DurationA: accessA.duration,
+			DurationB: accessB.duration,
+		})
+	}
+	sort.SliceStable(result.Timing, func(i, j int) bool {
+		di, dj := abs(result.Timing[i].DurationB-result.Timing[i].DurationA), abs(result.Timing[j].DurationB-result.Timing[j].DurationA)
+		if di != dj {
+			return di > dj
+		}
+		return abs(result.Timing[i].OffsetB-result.Timing[i].OffsetA) > abs(result.Timing[j].OffsetB-result.Timing[j].OffsetA)
+	})
+	return result
+}
+
+// increasing marks a longest increasing subsequence. Each path is in a
+// recording once, so this is the longest common subsequence of the paths.
+func increasing(sequence []int) []bool {
+
+	// tails[k] is the index ending the best subsequence of length k+1
+	tails := []int{}
+	previous := make([]int, len(sequence))
+	for i, value := range sequence {
+		k := sort.Search(len(tails), func(k int) bool {
+			return sequence[tails[k]] >= value
+		})
+		previous[i] = -1
+		if k > 0 {
+			previous[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	marked := make([]bool, len(sequence))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = previous[i] {
+			marked[i] = true
+		}
+	}
+	return marked
+}
+
+// WriteText writes the difference, with the top timing changes
+func (r *Result) WriteText(w io.Writer, top int) error {
+	fmt.Fprintf(w, "--- %s (%d events, %d paths)\n", r.A.Name, r.A.Events, r.A.Paths)
+	fmt.Fprintf(w, "+++ %s (%d events, %d paths)\n", r.B.Name, r.B.Events, r.B.Paths)
+	fmt.Fprintf(w, "Common paths: %d, in the same order: %d\n", r.Common, r.InOrder)
+
+	fmt.Fprintf(w, "Removed paths: %d\n", len(r.Removed))
+	for _, path := range r.Removed {
+		fmt.Fprintf(w, "- %s\n", path)
+	}
+	fmt.Fprintf(w, "Added paths: %d\n", len(r.Added))
+	for _, path := range r.Added {
+		fmt.Fprintf(w, "+ %s\n", path)
+	}
+	fmt.Fprintf(w, "Reordered regions: %d\n", len(r.Reordered))
+	for _, region := range r.Reordered {
+		fmt.Fprintf(w, "~ %d paths from #%d to #%d\n", len(region.Paths), region.A, region.B)
+		for _, path := range region.Paths {
+			fmt.Fprintf(w, "    %s\n", path)
+		}
+	}
+
+	timing := r.Timing
+	if top >= 0 && len(timing) > top {
+		timing = timing[:top]
+	}
+	if len(timing) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "Timing changes:")
+	_, err := fmt.Fprintf(w, "%11s %12s %12s %12s %12s  %s\n", "Count", "Offset", "Change", "Duration", "Change", "Path")
+	for _, delta := range timing {
+		_, err = fmt.Fprintf(w, "%11s %12s %12s %12s %12s  %s\n",
+			fmt.Sprintf("%d/%d", delta.CountA, delta.CountB),
+			time.Duration(delta.OffsetB), change(delta.OffsetB-delta.OffsetA),
+			time.Duration(delta.DurationB), change(delta.DurationB-delta.DurationA),
+			delta.Path,
+		)
+	}
+	return err
+}
+
+// change formats a signed difference in nanoseconds
+func change(ns int64) string {
+	if ns > 0 {
+		return "+" + time.Duration(ns).String()
+	}
+	return time.Duration(ns).String()
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// opens returns a recording that opens paths in order, one each nanosecond
+func opens(name string, paths ...string) *Recording {
+	events := []*event.Event{}
+	for i, path := range paths {
+		events = append(events, &event.Event{Timestamp: int64(i + 1), Type: event.Open, Path: path})
+	}
+	return NewRecording(name, events, Options{})
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name      string
+		a         []string
+		b         []string
+		inOrder   int
+		removed   []string
+		added     []string
+		reordered []Region
+	}{
+		{
+			name:      "same order",
+			a:         []string{"/a", "/b", "/c"},
+			b:         []string{"/a", "/b", "/c"},
+			inOrder:   3,
+			removed:   []string{},
+			added:     []string{},
+			reordered: []Region{},
+		},
+		{
+			name:      "removed and added",
+			a:         []string{"/a", "/b", "/c"},
+			b:         []string{"/a", "/d", "/c", "/e"},
+			inOrder:   2,
+			removed:   []string{"/b"},
+			added:     []string{"/d", "/e"},
+			reordered: []Region{},
+		},
+		{
+			name:      "swapped",
+			a:         []string{"/a", "/b", "/c", "/d"},
+			b:         []string{"/a", "/c", "/b", "/d"},
+			inOrder:   3,
+			removed:   []string{},
+			added:     []string{},
+			reordered: []Region{{A: 1, B: 2, Paths: []string{"/b"}}},
+		},
+		{
+			name:      "moved block",
+			a:         []string{"/1", "/2", "/3", "/4", "/5"},
+			b:         []string{"/4", "/5", "/1", "/2", "/3"},
+			inOrder:   3,
+			removed:   []string{},
+			added:     []string{},
+			reordered: []Region{{A: 3, B: 0, Paths: []string{"/4", "/5"}}},
+		},
+		{
+			name:      "reversed",
+			a:         []string{"/a", "/b", "/c"},
+			b:         []string{"/c", "/b", "/a"},
+			inOrder:   1,
+			removed:   []string{},
+			added:     []string{},
+			reordered: []Region{{A: 0, B: 2, Paths: []string{"/a"}}, {A: 1, B: 1, Paths: []string{"/b"}}},
+		},
+		{
+			// Of two alignments as long, the later paths are in order
+			name:      "tie with a removed path",
+			a:         []string{"/1", "/x", "/2", "/3", "/4"},
+			b:         []string{"/3", "/4", "/1", "/2"},
+			inOrder:   2,
+			removed:   []string{"/x"},
+			added:     []string{},
+			reordered: []Region{{A: 0, B: 2, Paths: []string{"/1", "/2"}}},
+		},
+		{
+			name:      "empty",
+			inOrder:   0,
+			removed:   []string{},
+			added:     []string{},
+			reordered: []Region{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Compare(opens("a", test.a...), opens("b", test.b...))
+			if result.InOrder != test.inOrder {
+				t.Errorf("Compare() in order = %d, want %d", result.InOrder, test.inOrder)
+			}
+			if common := len(test.a) - len(test.removed); result.Common != common {
+				t.Errorf("Compare() common = %d, want %d", result.Common, common)
+			}
+			if !reflect.DeepEqual(result.Removed, test.removed) {
+				t.Errorf("Compare() removed = %v, want %v", result.Removed, test.removed)
+			}
+			if !reflect.DeepEqual(result.Added, test.added) {
+				t.Errorf("Compare() added = %v, want %v", result.Added, test.added)
+			}
+			if !reflect.DeepEqual(result.Reordered, test.reordered) {
+				t.Errorf("Compare() reordered = %v, want %v", result.Reordered, test.reordered)
+			}
+		})
+	}
+}
+
+func TestIncreasing(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence []int
+		marked   []bool
+	}{
+		{"empty", []int{}, []bool{}},
+		{"sorted", []int{0, 1, 2}, []bool{true, true, true}},
+		{"one out of place", []int{0, 2, 1, 3}, []bool{true, false, true, true}},
+		{"decreasing", []int{2, 1, 0}, []bool{false, false, true}},
+		{"longest is later", []int{5, 0, 1, 2}, []bool{false, true, true, true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := increasing(test.sequence); !reflect.DeepEqual(got, test.marked) {
+				t.Errorf("increasing(%v) = %v, want %v", test.sequence, got, test.marked)
+			}
+		})
+	}
+}
+
+func TestNewRecording(t *testing.T) {
+	events := []*event.Event{
+		{Timestamp: 100, Type: event.Lookup, Path: "/lib"},
+		{Timestamp: 110, Type: event.Open, Path: "/lib/libc.so.6", Duration: 5},
+		{Timestamp: 120, Type: event.Open, Path: "/lib/libm.so.6", Errno: 2},
+		{Timestamp: 130, Type: event.Open, Path: "/lib/libc.so.6", Duration: 7},
+		{Timestamp: 140, Type: event.Complete},
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+		order   []string
+		events  int
+	}{
+		{"all events", Options{}, []string{"/lib", "/lib/libc.so.6"}, 3},
+		{"opens", Options{Events: event.NewMask(event.Open)}, []string{"/lib/libc.so.6"}, 2},
+		{"opens with failures", Options{Events: event.NewMask(event.Open), Failed: true}, []string{"/lib/libc.so.6", "/lib/libm.so.6"}, 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRecording("test", events, test.options)
+			if !reflect.DeepEqual(r.order, test.order) {
+				t.Errorf("NewRecording() order = %v, want %v", r.order, test.order)
+			}
+			if r.Events != test.events || r.Paths != len(test.order) {
+				t.Errorf("NewRecording() = %d events, %d paths, want %d, %d", r.Events, r.Paths, test.events, len(test.order))
+			}
+		})
+	}
+
+	// The first access has the offset, and durations are added up
+	r := NewRecording("test", events, Options{})
+	if libc := r.access["/lib/libc.so.6"]; libc.offset != 10 || libc.count != 2 || libc.duration != 12 {
+		t.Errorf("NewRecording() libc = %+v, want offset 10, count 2, duration 12", *libc)
+	}
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A Broadcaster sends events to subscribers without blocking the
+// filesystem. Each subscriber has a buffer, and when a slow subscriber
+// falls behind its buffer fills and new events are dropped (and counted)
+// for it alone. Events are shared between subscribers and must not be
+// changed after they are published. A nil Broadcaster discards events.
+type Broadcaster struct {
+	mutex       sync.RWMutex
+	subscribers map[*Subscription]bool
+	closed      bool
+}
+
+// A Subscription receives events of the types in its mask
+type Subscription struct {
+	events  chan *Event
+	mask    Mask
+	dropped atomic.Uint64
+}
+
+// NewBroadcaster returns a broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: map[*Subscription]bool{}}
+}
+
+// Subscribe adds a subscriber for event types in a mask (nil for all)
+// with a buffer of events. A subscription to a closed broadcaster
+// receives no events.
+func (b *Broadcaster) Subscribe(buffer int, mask Mask) *Subscription {
+	s := &Subscription{events: make(chan *Event, max(buffer, 1)), mask: mask}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subscribers[s] = true
+	return s
+}
+
+// Unsubscribe removes a subscriber and closes its events
+func (b *Broadcaster) Unsubscribe(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.subscribers[s] {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Publish sends an event to each subscriber with room in its buffer
+func (b *Broadcaster) Publish(e *Event) {
+	if b == nil {
+		return
+	}
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for s := range b.subscribers {
+		if !s.mask.Has(e.Type) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Close ends all subscriptions. Subscribers still receive the events
+// in their buffers.
+func (b *Broadcaster) Close() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for s := range b.subscribers {
+		close(s.events)
+	}
+	b.subscribers = map[*Subscription]bool{}
+	b.closed = true
+}
+
+// Events returns the events for a subscriber, closed when the
+// subscription ends
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
@@ -0,0 +1,74 @@
+package event
+
+// Types of events recorded by the filesystems
+const (
+	Lookup    = "Lookup"
+	Open      = "Open"
+	Create    = "Create"
+	Close     = "Close"
+	Readlink  = "Readlink"
+	Read      = "Read"
+	Write     = "Write"
+	Getattr   = "Getattr"
+	Opendir   = "Opendir"
+	Readdir   = "Readdir"
+	Getxattr  = "Getxattr"
+	Listxattr = "Listxattr"
+	Unlink    = "Unlink"
+	Rename    = "Rename"
+	Mkdir     = "Mkdir"
+	Fsync     = "Fsync"
+	Release   = "Release"
+	Complete  = "Complete"
+
+	// Derived when a file handle is released, from its open to its release
+	Session = "Session"
+)
+
+// An Event is a filesystem operation seen by a fuse filesystem
+// Fields that do not apply to an event type are left empty.
+type Event struct {
+
+	// Unix time in nanoseconds
+	Timestamp int64  `json:"timestamp"`
+	Type      string `json:"type"`
+	Path      string `json:"path,omitempty"`
+
+	// File descriptor (for an open file), and the handle of the open,
+	// which is unique in a recording (file descriptors are reused)
+	Fd     int   `json:"fd,omitempty"`
+	Handle int64 `json:"handle,omitempty"`
+
+	// Process that made the call, and its parent
+	Pid  int `json:"pid,omitempty"`
+	Ppid int `json:"ppid,omitempty"`
+
+	// User and group of the process
+	Uid int `json:"uid,omitempty"`
+	Gid int `json:"gid,omitempty"`
+
+	// Command name and executable of the process
+	Comm string `json:"comm,omitempty"`
+	Exe  string `json:"exe,omitempty"`
+
+	// MPI rank of the process (empty if it was not launched with MPI)
+	Rank string `json:"rank,omitempty"`
+
+	// Result of the operation (0 for success) and how long it took
+	// in nanoseconds
+	Errno    int   `json:"errno,omitempty"`
+	Duration int64 `json:"duration,omitempty"`
+
+	// Size of the file, bytes read or written, or entries in a directory
+	Size int64 `json:"size,omitempty"`
+
+	// Offset of a read or write
+	Offset int64 `json:"offset,omitempty"`
+
+	// New path of a rename, target of a link, or name of an extended attribute
+	Target string `json:"target,omitempty"`
+
+	// Bytes read and written through a file handle (for a session)
+	BytesRead    int64 `json:"bytesRead,omitempty"`
+	BytesWritten int64 `json:"bytesWritten,omitempty"`
+}
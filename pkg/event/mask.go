@@ -0,0 +1,58 @@
+package event
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Types of filesystem operations that can be recorded
+var Types = []string{
+	Lookup, Open, Create, Close, Readlink, Read, Write, Getattr, Opendir,
+	Readdir, Getxattr, Listxattr, Unlink, Rename, Mkdir, Fsync, Release, Session,
+}
+
+// Types recorded by default, which keeps overhead low
+var DefaultTypes = []string{Lookup, Open, Create, Close, Release, Session}
+
+// A Mask is the set of event types to record (nil records all)
+type Mask map[string]bool
+
+// NewMask returns a mask for event types
+func NewMask(types ...string) Mask {
+	mask := Mask{}
+	for _, eventType := range types {
+		mask[eventType] = true
+	}
+	return mask
+}
+
+// ParseMask parses a comma separated list of event types (in any case),
+// or "all" for every type
+func ParseMask(value string) (Mask, error) {
+	if value == "" {
+		return NewMask(DefaultTypes...), nil
+	}
+	mask := Mask{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, "all") {
+			return NewMask(Types...), nil
+		}
+		found := false
+		for _, eventType := range Types {
+			if strings.EqualFold(name, eventType) {
+				mask[eventType] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown event type %q, choices are all, %s", name, strings.Join(Types, ", "))
+		}
+	}
+	return mask, nil
+}
+
+// Has determines if an event type is in the mask
+func (m Mask) Has(eventType string) bool {
+	return m == nil || m[eventType]
+}
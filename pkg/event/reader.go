@@ -0,0 +1,329 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Largest binary record we will read
+const maxRecordSize = 1 << 20
+
+// A Reader reads events from any format, detected from the content
+type Reader struct {
+	reader *bufio.Reader
+	format string
+	line   int
+}
+
+// NewReader detects the format of the events and returns a reader
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{reader: bufio.NewReader(r), format: FormatText}
+	start, err := reader.reader.Peek(len(binaryMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(start, binaryMagic) {
+		reader.format = FormatBinary
+		reader.reader.Discard(len(binaryMagic))
+	} else if len(start) > 0 && start[0] == '{' {
+		reader.format = FormatJSON
+	}
+	return reader, nil
+}
+
+// Format returns the detected format
+func (r *Reader) Format() string {
+	return r.format
+}
+
+// Read returns the next event, or io.EOF at the end
+func (r *Reader) Read() (*Event, error) {
+	if r.format == FormatBinary {
+		return r.readBinary()
+	}
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, err
+		}
+		r.line++
+		line = strings.TrimRight(line, "\r\n")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var e *Event
+		if r.format == FormatJSON {
+			e = &Event{}
+			err = json.Unmarshal([]byte(line), e)
+		} else {
+			e, err = parseText(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return e, nil
+	}
+}
+
+// ReadAll reads all remaining events
+func (r *Reader) ReadAll() ([]*Event, error) {
+	events := []*Event{}
+	for {
+		e, err := r.Read()
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+}
+
+// ReadFile reads all events from a file in any format
+func ReadFile(path string) ([]*Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader, err := NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	events, err := reader.ReadAll()
+	if err != nil {
+		return events, fmt.Errorf("%s: %w", path, err)
+	}
+	return events, nil
+}
+
+// parseText parses a text line. The date, time and source come first,
+// then the timestamp, type, and path (with a tab before the fd and each
+// key=value field)
+func parseText(line string) (*Event, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("cannot parse event %q", line)
+	}
+	timestamp, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse timestamp %q", fields[3])
+	}
+	e := &Event{Timestamp: timestamp, Type: fields[4]}
+
+	// The path is the rest of the line (it can have spaces), before the
+	// fields from the end that are known
+	rest := strings.TrimRight(skipFields(line, 5), " ")
+	for {
+		idx := strings.LastIndex(rest, "\t")
+		if idx < 0 {
+			break
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(rest[idx+1:]), "=")
+		if !ok || !setTextField(e, key, value) {
+			break
+		}
+		rest = rest[:idx]
+	}
+	if idx := strings.LastIndex(rest, "\t"); idx >= 0 {
+		fd, err := strconv.Atoi(strings.TrimSpace(rest[idx+1:]))
+		if err == nil {
+			e.Fd = fd
+			rest = rest[:idx]
+		}
+	}
+	e.Path = rest
+	return e, nil
+}
+
+// setTextField sets a key=value field of a text line, and returns false for
+// a key that is not known or a value that is not valid
+func setTextField(e *Event, key, value string) bool {
+	switch key {
+	case "comm":
+		e.Comm = value
+		return true
+	case "exe":
+		e.Exe = value
+		return true
+	case "rank":
+		e.Rank = value
+		return true
+	case "target":
+		e.Target = value
+		return true
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+	switch key {
+	case "errno":
+		e.Errno = int(number)
+	case "dur":
+		e.Duration = number
+	case "size":
+		e.Size = number
+	case "offset":
+		e.Offset = number
+	case "pid":
+		e.Pid = int(number)
+	case "ppid":
+		e.Ppid = int(number)
+	case "uid":
+		e.Uid = int(number)
+	case "gid":
+		e.Gid = int(number)
+	case "handle":
+		e.Handle = number
+	case "bytesRead":
+		e.BytesRead = number
+	case "bytesWritten":
+		e.BytesWritten = number
+	default:
+		return false
+	}
+	return true
+}
+
+// skipFields returns a line after a number of space separated fields
+func skipFields(line string, count int) string {
+	for i := 0; i < count; i++ {
+		line = strings.TrimLeft(line, " ")
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			return ""
+		}
+		line = line[end:]
+	}
+	return strings.TrimLeft(line, " ")
+}
+
+// readBinary reads a length-prefixed record
+func (r *Reader) readBinary() (*Event, error) {
+	size, err := readUvarint(r.reader)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxRecordSize {
+		return nil, fmt.Errorf("record of %d bytes is too large", size)
+	}
+	message := make([]byte, size)
+	_, err = io.ReadFull(r.reader, message)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return decode(message)
+}
+
+// readUvarint reads a varint, returning io.EOF only at a record boundary
+func readUvarint(r *bufio.Reader) (uint64, error) {
+	value := uint64(0)
+	for shift := 0; shift < 64; shift += 7 {
+		b, err := r.ReadByte()
+		if err == io.EOF && shift > 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return value, nil
+		}
+	}
+	return 0, errors.New("record length overflows")
+}
+
+// decode decodes a binary record, skipping unknown fields
+func decode(b []byte) (*Event, error) {
+	e := &Event{}
+	for len(b) > 0 {
+		field, wireType, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch {
+		case wireType == protowire.VarintType:
+			value, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			setInt(e, field, protowire.DecodeZigZag(value))
+		case wireType == protowire.BytesType:
+			value, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+			setString(e, field, value)
+		default:
+			n := protowire.ConsumeFieldValue(field, wireType, b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return e, nil
+}
+
+func setInt(e *Event, field protowire.Number, value int64) {
+	switch field {
+	case fieldTimestamp:
+		e.Timestamp = value
+	case fieldFd:
+		e.Fd = int(value)
+	case fieldPid:
+		e.Pid = int(value)
+	case fieldErrno:
+		e.Errno = int(value)
+	case fieldSize:
+		e.Size = value
+	case fieldPpid:
+		e.Ppid = int(value)
+	case fieldUid:
+		e.Uid = int(value)
+	case fieldGid:
+		e.Gid = int(value)
+	case fieldOffset:
+		e.Offset = value
+	case fieldDuration:
+		e.Duration = value
+	case fieldHandle:
+		e.Handle = value
+	case fieldBytesRead:
+		e.BytesRead = value
+	case fieldBytesWritten:
+		e.BytesWritten = value
+	}
+}
+
+func setString(e *Event, field protowire.Number, value string) {
+	switch field {
+	case fieldType:
+		e.Type = value
+	case fieldPath:
+		e.Path = value
+	case fieldComm:
+		e.Comm = value
+	case fieldExe:
+		e.Exe = value
+	case fieldTarget:
+		e.Target = value
+	case fieldRank:
+		e.Rank = value
+	}
+}
@@ -0,0 +1,206 @@
+package event
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestRoundTrip(t *testing.T) {
+	events := []*Event{
+		{Timestamp: 1731062779714551943, Type: Lookup, Path: "/etc/hosts", Pid: 12, Ppid: 1, Uid: 1000, Gid: 1000, Comm: "cat", Exe: "/usr/bin/cat", Duration: 1500},
+		{Timestamp: 1731062779714551944, Type: Open, Path: "/etc/hosts", Fd: 3, Handle: 1, Pid: 12, Rank: "0"},
+		{Timestamp: 1731062779714551945, Type: Open, Path: "/etc/missing", Errno: 2},
+		{Timestamp: 1731062779714551946, Type: Read, Path: "/etc/hosts", Fd: 3, Handle: 1, Size: 4096, Offset: 8192},
+		{Timestamp: 1731062779714551947, Type: Readlink, Path: "/usr/lib/a file with spaces", Target: "b"},
+		{Timestamp: 1731062779714551948, Type: Session, Path: "/etc/hosts", Fd: 3, Handle: 1, Duration: 9000, BytesRead: 4096, BytesWritten: 12},
+		{Timestamp: 1731062779714551949, Type: Getattr, Path: "/negative", Size: -1, Offset: -4096},
+	}
+
+	for _, format := range Formats {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "events")
+
+			// Events are appended, so the file is written twice
+			for _, part := range [][]*Event{events[:3], events[3:]} {
+				writer, err := OpenFile(path, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, e := range part {
+					err = writer.Write(e)
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				writer.Close()
+			}
+
+			got, err := ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(events) {
+				t.Fatalf("ReadFile() = %d events, want %d", len(got), len(events))
+			}
+			for i, e := range events {
+				if !reflect.DeepEqual(got[i], e) {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], e)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+	}{
+		{"text", "2024/11/08 10:46:19 fs-record: 1 Open      /etc/hosts\n", FormatText},
+		{"jsonl", `{"timestamp": 1, "type": "Open"}` + "\n", FormatJSON},
+		{"binary", string(binaryMagic), FormatBinary},
+		{"empty", "", FormatText},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, err := NewReader(strings.NewReader(test.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reader.Format() != test.format {
+				t.Errorf("Format() = %q, want %q", reader.Format(), test.format)
+			}
+		})
+	}
+}
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		event *Event
+	}{
+		{
+			name:  "open",
+			line:  "2024/11/08 10:46:19 fs-record: 1731062779714551943 Open      /etc/hosts\t3",
+			event: &Event{Timestamp: 1731062779714551943, Type: Open, Path: "/etc/hosts", Fd: 3},
+		},
+		{
+			name:  "source file and line",
+			line:  "2024/11/08 10:46:19 loopback.go:46: 1731062779714551943 Lookup    /etc/hosts",
+			event: &Event{Timestamp: 1731062779714551943, Type: Lookup, Path: "/etc/hosts"},
+		},
+		{
+			name:  "failed",
+			line:  "2024/11/08 10:46:19 fs-record: 1 Open      /etc/missing\terrno=2\tdur=1500",
+			event: &Event{Timestamp: 1, Type: Open, Path: "/etc/missing", Errno: 2, Duration: 1500},
+		},
+		{
+			name:  "path with spaces",
+			line:  "2024/11/08 10:46:19 fs-record: 1 Open      /a b/c d\t4",
+			event: &Event{Timestamp: 1, Type: Open, Path: "/a b/c d", Fd: 4},
+		},
+		{
+			name:  "long type",
+			line:  "2024/11/08 10:46:19 fs-record: 1 Listxattr /etc",
+			event: &Event{Timestamp: 1, Type: Listxattr, Path: "/etc"},
+		},
+		{
+			name:  "caller",
+			line:  "2024/11/08 10:46:19 fs-record: 1 Open      /etc/hosts\t3\tpid=12\tppid=1\tuid=1000\tgid=100\tcomm=Web Content\texe=/usr/bin/firefox",
+			event: &Event{Timestamp: 1, Type: Open, Path: "/etc/hosts", Fd: 3, Pid: 12, Ppid: 1, Uid: 1000, Gid: 100, Comm: "Web Content", Exe: "/usr/bin/firefox"},
+		},
+		{
+			name:  "unknown field is in the path",
+			line:  "2024/11/08 10:46:19 fs-record: 1 Lookup    /etc/a\tcolor=red\tpid=12",
+			event: &Event{Timestamp: 1, Type: Lookup, Path: "/etc/a\tcolor=red", Pid: 12},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e, err := parseText(test.line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(e, test.event) {
+				t.Errorf("parseText() = %+v, want %+v", e, test.event)
+			}
+		})
+	}
+}
+
+func TestParseTextErrors(t *testing.T) {
+	for line, message := range map[string]string{
+		"2024/11/08 10:46:19 fs-record: 1":             "cannot parse event",
+		"2024/11/08 10:46:19 fs-record: now Open /etc": "cannot parse timestamp",
+	} {
+		_, err := parseText(line)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("parseText(%q) = %v, want %q", line, err, message)
+		}
+	}
+}
+
+// record returns a length-prefixed binary record
+func record(message []byte) string {
+	return string(append(protowire.AppendVarint(nil, uint64(len(message))), message...))
+}
+
+func TestReadBinary(t *testing.T) {
+	open := encode(&Event{Timestamp: 1, Type: Open, Path: "/etc/hosts", Fd: 3})
+
+	// Fields from a newer writer are skipped
+	newer := protowire.AppendVarint(protowire.AppendTag(nil, 100, protowire.VarintType), 5)
+
+	tests := []struct {
+		name   string
+		data   string
+		events []*Event
+	}{
+		{"empty", "", []*Event{}},
+		{"records", record(open) + record(encode(&Event{Timestamp: 2, Type: Close})), []*Event{{Timestamp: 1, Type: Open, Path: "/etc/hosts", Fd: 3}, {Timestamp: 2, Type: Close}}},
+		{"unknown field", record(append(newer, open...)), []*Event{{Timestamp: 1, Type: Open, Path: "/etc/hosts", Fd: 3}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := readBinary(t, test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(events, test.events) {
+				t.Errorf("ReadAll() = %+v, want %+v", events, test.events)
+			}
+		})
+	}
+}
+
+// A damaged file is an error rather than events made up from its bytes
+func TestReadBinaryDamaged(t *testing.T) {
+	open := record(encode(&Event{Timestamp: 1, Type: Open, Path: "/etc/hosts", Fd: 3}))
+	for _, data := range []string{open[:5], "\x80", record([]byte{0x0a, 0x10})} {
+		_, err := readBinary(t, data)
+		if !errors.Is(err, io.ErrUnexpectedEOF) && (err == nil || !strings.Contains(err.Error(), "unexpected EOF")) {
+			t.Errorf("ReadAll(%q) = %v, want an unexpected EOF", data, err)
+		}
+	}
+	_, err := readBinary(t, string(protowire.AppendVarint(nil, maxRecordSize+1)))
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("ReadAll() = %v for a record larger than %d bytes", err, maxRecordSize)
+	}
+}
+
+// readBinary reads the events of a binary file with its header
+func readBinary(t *testing.T, data string) ([]*Event, error) {
+	reader, err := NewReader(strings.NewReader(string(binaryMagic) + data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader.ReadAll()
+}
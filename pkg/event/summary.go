@@ -0,0 +1,174 @@
+package event
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Latencies are counted in log scale buckets, with this many buckets
+// for each power of two (a resolution of about 12%)
+const (
+	subBucketBits = 3
+	subBuckets    = 1 << subBucketBits
+)
+
+// A Summary counts events by type, failures, latency and path.
+// It is safe for concurrent use.
+type Summary struct {
+	mutex sync.Mutex
+	ops   map[string]*opSummary
+	paths map[string]int64
+}
+
+// opSummary holds counts for one type of event
+type opSummary struct {
+	count    int64
+	failures map[int]int64
+	latency  map[int]int64
+}
+
+// NewSummary returns an empty summary
+func NewSummary() *Summary {
+	return &Summary{
+		ops:   map[string]*opSummary{},
+		paths: map[string]int64{},
+	}
+}
+
+// Add counts an event
+func (s *Summary) Add(e *Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	op, ok := s.ops[e.Type]
+	if !ok {
+		op = &opSummary{failures: map[int]int64{}, latency: map[int]int64{}}
+		s.ops[e.Type] = op
+	}
+	op.count++
+	if e.Errno != 0 {
+		op.failures[e.Errno]++
+	}
+	op.latency[latencyBucket(e.Duration)]++
+	if e.Path != "" {
+		s.paths[e.Path]++
+	}
+}
+
+// Write writes a table of counts, failures and latency for each type of
+// event, followed by the most accessed paths
+func (s *Summary) Write(w io.Writer, top int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	types := make([]string, 0, len(s.ops))
+	for name := range s.ops {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+
+	fmt.Fprintf(w, "%-10s %8s %8s %10s %10s\n", "Operation", "Count", "Failed", "p50", "p99")
+	for _, name := range types {
+		op := s.ops[name]
+		failed := int64(0)
+		for _, count := range op.failures {
+			failed += count
+		}
+		fmt.Fprintf(w, "%-10s %8d %8d %10s %10s\n", name, op.count, failed, op.percentile(0.5), op.percentile(0.99))
+	}
+
+	for _, name := range types {
+		op := s.ops[name]
+		if len(op.failures) == 0 {
+			continue
+		}
+		errnos := make([]int, 0, len(op.failures))
+		for errno := range op.failures {
+			errnos = append(errnos, errno)
+		}
+		sort.Slice(errnos, func(i, j int) bool {
+			return op.failures[errnos[i]] > op.failures[errnos[j]]
+		})
+		fmt.Fprintf(w, "%s failures:", name)
+		for _, errno := range errnos {
+			fmt.Fprintf(w, " %s %d", ErrnoName(errno), op.failures[errno])
+		}
+		fmt.Fprintln(w)
+	}
+
+	paths := make([]string, 0, len(s.paths))
+	for path := range s.paths {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if s.paths[paths[i]] == s.paths[paths[j]] {
+			return paths[i] < paths[j]
+		}
+		return s.paths[paths[i]] > s.paths[paths[j]]
+	})
+	if len(paths) > top {
+		paths = paths[:top]
+	}
+	if len(paths) > 0 {
+		fmt.Fprintln(w, "Top paths:")
+	}
+	for _, path := range paths {
+		fmt.Fprintf(w, "%8d  %s\n", s.paths[path], path)
+	}
+	return nil
+}
+
+// percentile returns the lower bound of the latency bucket at a quantile
+func (op *opSummary) percentile(quantile float64) time.Duration {
+	buckets := make([]int, 0, len(op.latency))
+	for bucket := range op.latency {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+
+	rank := int64(quantile * float64(op.count-1))
+	seen := int64(0)
+	for _, bucket := range buckets {
+		seen += op.latency[bucket]
+		if seen > rank {
+			return time.Duration(bucketValue(bucket))
+		}
+	}
+	return 0
+}
+
+// latencyBucket returns the bucket for a duration in nanoseconds. Small
+// values have their own bucket, and larger values are grouped by their
+// most significant bits.
+func latencyBucket(ns int64) int {
+	if ns < subBuckets {
+		return int(max(ns, 0))
+	}
+	exponent := bits.Len64(uint64(ns)) - 1
+	mantissa := (ns >> (exponent - subBucketBits)) & (subBuckets - 1)
+	return (exponent-subBucketBits+1)*subBuckets + int(mantissa)
+}
+
+// bucketValue returns the smallest duration in a bucket
+func bucketValue(bucket int) int64 {
+	if bucket < subBuckets {
+		return int64(bucket)
+	}
+	exponent := bucket/subBuckets + subBucketBits - 1
+	mantissa := int64(bucket % subBuckets)
+	return (subBuckets + mantissa) << (exponent - subBucketBits)
+}
+
+// ErrnoName returns the symbolic name of an errno (e.g., ENOENT)
+func ErrnoName(errno int) string {
+	name := unix.ErrnoName(unix.Errno(errno))
+	if name == "" {
+		return fmt.Sprintf("errno %d", errno)
+	}
+	return name
+}
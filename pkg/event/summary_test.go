@@ -0,0 +1,112 @@
+package event
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLatencyBucket(t *testing.T) {
+	tests := []struct {
+		ns     int64
+		bucket int
+		value  int64
+	}{
+		{-5, 0, 0},
+		{0, 0, 0},
+		{7, 7, 7},
+		{8, 8, 8},
+		{15, 15, 15},
+		{16, 16, 16},
+		{17, 16, 16},
+		{18, 17, 18},
+		{1000, 63, 960},
+		{1500, 67, 1408},
+		{math.MaxInt64, 487, 15 << 59},
+	}
+	for _, test := range tests {
+		bucket := latencyBucket(test.ns)
+		if bucket != test.bucket {
+			t.Errorf("latencyBucket(%d) = %d, want %d", test.ns, bucket, test.bucket)
+		}
+		if value := bucketValue(bucket); value != test.value {
+			t.Errorf("bucketValue(%d) = %d, want %d", bucket, value, test.value)
+		}
+	}
+
+	// Each duration is in the bucket that starts at or before it, within
+	// the resolution, and before the next bucket
+	for ns := int64(0); ns < 1<<20; ns += 1 + ns/64 {
+		bucket := latencyBucket(ns)
+		low, high := bucketValue(bucket), bucketValue(bucket+1)
+		if ns < low || ns >= high || float64(ns-low) > float64(low)/subBuckets {
+			t.Fatalf("%d is in bucket %d of [%d, %d)", ns, bucket, low, high)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		name      string
+		durations []int64
+		quantile  float64
+		want      time.Duration
+	}{
+		{"median", []int64{1, 2, 3, 4, 5, 6, 7}, 0.5, 4},
+		{"p99 of 1 to 100", sequence(1, 100), 0.99, 96},
+		{"p50 of 1 to 100", sequence(1, 100), 0.5, 48},
+		{"one slow call", []int64{10, 10, 10, 1000000}, 0.99, 10},
+		{"single", []int64{1500}, 0.5, 1408},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			summary := NewSummary()
+			for _, duration := range test.durations {
+				summary.Add(&Event{Type: Open, Duration: duration})
+			}
+			if got := summary.ops[Open].percentile(test.quantile); got != test.want {
+				t.Errorf("percentile(%v) = %v, want %v", test.quantile, got, test.want)
+			}
+		})
+	}
+}
+
+// sequence returns the integers from first to last
+func sequence(first, last int64) []int64 {
+	values := []int64{}
+	for value := first; value <= last; value++ {
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestSummaryWrite(t *testing.T) {
+	summary := NewSummary()
+	for _, e := range []*Event{
+		{Type: Lookup, Path: "/etc/hosts", Duration: 1000},
+		{Type: Open, Path: "/etc/hosts", Duration: 2000},
+		{Type: Open, Path: "/etc/missing", Errno: 2},
+		{Type: Open, Path: "/etc/missing", Errno: 2},
+		{Type: Open, Path: "/etc/shadow", Errno: 13},
+	} {
+		summary.Add(e)
+	}
+
+	out := strings.Builder{}
+	err := summary.Write(&out, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Operation     Count   Failed        p50        p99
+Lookup            1        0      960ns      960ns
+Open              4        3         0s         0s
+Open failures: ENOENT 2 EACCES 1
+Top paths:
+       2  /etc/hosts
+       2  /etc/missing
+`
+	if out.String() != want {
+		t.Errorf("Write() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
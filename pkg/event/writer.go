@@ -0,0 +1,252 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Formats for writing events
+const (
+
+	// Text lines, the original fs-record format
+	FormatText = "text"
+
+	// One json object per line
+	FormatJSON = "jsonl"
+
+	// Length-prefixed records (protobuf wire format) after a header
+	FormatBinary = "binary"
+)
+
+// Formats that can be written
+var Formats = []string{FormatText, FormatJSON, FormatBinary}
+
+// Source of events in the text format. The original format had the file
+// and line that logged each event (always the same call in the recorder),
+// and the column is kept for parsers of it.
+const textSource = "fs-record"
+
+// Header at the start of a binary event file
+var binaryMagic = []byte("fsrec\x00\x01\n")
+
+// Field numbers for the binary format
+const (
+	fieldTimestamp protowire.Number = iota + 1
+	fieldType
+	fieldPath
+	fieldFd
+	fieldPid
+	fieldErrno
+	fieldSize
+	fieldPpid
+	fieldUid
+	fieldGid
+	fieldComm
+	fieldExe
+	fieldOffset
+	fieldTarget
+	fieldDuration
+
+	// 16 is the count of dropped events, which only a stream has
+	fieldHandle protowire.Number = iota + 2
+	fieldBytesRead
+	fieldBytesWritten
+	fieldRank
+)
+
+// A Writer writes events in a format. Each event is written with a single
+// write, but writers are not safe for concurrent use.
+type Writer interface {
+	Write(e *Event) error
+	Close() error
+}
+
+// NewWriter returns a writer for a format. A binary writer expects to be
+// at the start of the output (or after an existing header).
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatText, "":
+		return &textWriter{w: w}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w}, nil
+	case FormatBinary:
+		return &binaryWriter{w: w}, nil
+	}
+	return nil, fmt.Errorf("unknown event format %q, choices are %s", format, strings.Join(Formats, ", "))
+}
+
+// OpenFile opens a file for events in a format, appending if it exists
+func OpenFile(path, format string) (Writer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := NewWriter(file, format)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// A binary file starts with a header so the reader can detect it
+	if format == FormatBinary {
+		info, err := file.Stat()
+		if err == nil && info.Size() == 0 {
+			_, err = file.Write(binaryMagic)
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &fileWriter{Writer: writer, file: file}, nil
+}
+
+// fileWriter closes the file under a writer
+type fileWriter struct {
+	Writer
+	file *os.File
+}
+
+func (w *fileWriter) Close() error {
+	w.Writer.Close()
+	return w.file.Close()
+}
+
+// textWriter writes lines of the form:
+// 2024/11/08 10:46:19 fs-record: 1731062779714551943 Open      /etc/hosts\t3\tpid=12\tcomm=cat
+// The path is followed by the fd and the other fields that are set, each
+// after a tab, as key=value (e.g., "\terrno=2" for a failed operation).
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) Write(e *Event) error {
+	path := e.Path
+	if e.Fd != 0 {
+		path = fmt.Sprintf("%s\t%d", path, e.Fd)
+	}
+	for _, field := range textFields(e) {
+		path += "\t" + field
+	}
+	stamp := time.Unix(0, e.Timestamp).Format("2006/01/02 15:04:05")
+	line := fmt.Sprintf("%s %s: %d %-*s%-*s\n", stamp, textSource, e.Timestamp, 10, e.Type, 10, path)
+	_, err := io.WriteString(t.w, line)
+	return err
+}
+
+func (t *textWriter) Close() error {
+	return nil
+}
+
+// textFields returns the fields of an event that are set, other than the
+// timestamp, type, path and fd, as key=value
+func textFields(e *Event) []string {
+	fields := []string{}
+	addInt := func(key string, value int64) {
+		if value != 0 {
+			fields = append(fields, fmt.Sprintf("%s=%d", key, value))
+		}
+	}
+	addString := func(key, value string) {
+		if value != "" {
+			fields = append(fields, key+"="+value)
+		}
+	}
+	addInt("errno", int64(e.Errno))
+	addInt("dur", e.Duration)
+	addInt("size", e.Size)
+	addInt("offset", e.Offset)
+	addString("target", e.Target)
+	addInt("pid", int64(e.Pid))
+	addInt("ppid", int64(e.Ppid))
+	addInt("uid", int64(e.Uid))
+	addInt("gid", int64(e.Gid))
+	addString("comm", e.Comm)
+	addString("exe", e.Exe)
+	addString("rank", e.Rank)
+	addInt("handle", e.Handle)
+	addInt("bytesRead", e.BytesRead)
+	addInt("bytesWritten", e.BytesWritten)
+	return fields
+}
+
+// jsonWriter writes one json object per line
+type jsonWriter struct {
+	w io.Writer
+}
+
+func (j *jsonWriter) Write(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = j.w.Write(append(data, '\n'))
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	return nil
+}
+
+// binaryWriter writes each event as a varint length and a message
+type binaryWriter struct {
+	w io.Writer
+}
+
+func (b *binaryWriter) Write(e *Event) error {
+	message := encode(e)
+	record := protowire.AppendVarint(make([]byte, 0, len(message)+4), uint64(len(message)))
+	_, err := b.w.Write(append(record, message...))
+	return err
+}
+
+func (b *binaryWriter) Close() error {
+	return nil
+}
+
+// encode encodes the fields of an event that are set
+func encode(e *Event) []byte {
+	b := []byte{}
+	b = appendInt(b, fieldTimestamp, e.Timestamp)
+	b = appendString(b, fieldType, e.Type)
+	b = appendString(b, fieldPath, e.Path)
+	b = appendInt(b, fieldFd, int64(e.Fd))
+	b = appendInt(b, fieldPid, int64(e.Pid))
+	b = appendInt(b, fieldErrno, int64(e.Errno))
+	b = appendInt(b, fieldSize, e.Size)
+	b = appendInt(b, fieldPpid, int64(e.Ppid))
+	b = appendInt(b, fieldUid, int64(e.Uid))
+	b = appendInt(b, fieldGid, int64(e.Gid))
+	b = appendString(b, fieldComm, e.Comm)
+	b = appendString(b, fieldExe, e.Exe)
+	b = appendInt(b, fieldOffset, e.Offset)
+	b = appendString(b, fieldTarget, e.Target)
+	b = appendInt(b, fieldDuration, e.Duration)
+	b = appendInt(b, fieldHandle, e.Handle)
+	b = appendInt(b, fieldBytesRead, e.BytesRead)
+	b = appendInt(b, fieldBytesWritten, e.BytesWritten)
+	b = appendString(b, fieldRank, e.Rank)
+	return b
+}
+
+func appendInt(b []byte, field protowire.Number, value int64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.VarintType)
+	return protowire.AppendVarint(b, protowire.EncodeZigZag(value))
+}
+
+func appendString(b []byte, field protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
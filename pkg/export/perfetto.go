@@ -0,0 +1,222 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// Formats recordings can be exported to
+const (
+	FormatPerfetto = "perfetto"
+)
+
+var Formats = []string{FormatPerfetto}
+
+// Track (thread) of a process for lookups and failed opens, which are
+// instant. Other tracks are file descriptors, and the fuse server never
+// opens a file as 0.
+const (
+	lookupTrack = 0
+	lookupName  = "Lookups"
+)
+
+// A traceEvent is an event in the Chrome trace format, which Perfetto
+// (ui.perfetto.dev) loads. Times are in microseconds.
+type traceEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Phase string         `json:"ph"`
+	Ts    float64        `json:"ts"`
+	Dur   *float64       `json:"dur,omitempty"`
+	Pid   int            `json:"pid"`
+	Tid   int            `json:"tid"`
+	Scope string         `json:"s,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// openFile is an open file waiting for its close
+type openFile struct {
+	path   string
+	fd     int
+	handle int64
+}
+
+// Perfetto writes events in the Chrome trace format. Each process is a
+// track, with an Open (or Create) and its Close as a slice on the track of
+// the file descriptor, and lookups as instant events on their own track.
+// A recording with timed sessions has a slice for each session instead,
+// from the open to the release of a handle. Other events are left out.
+// Events without a process (e.g., a text recording from before the caller
+// was written) are on one track named for the recording.
+func Perfetto(w io.Writer, name string, events []*event.Event) error {
+	sorted := make([]*event.Event, 0, len(events))
+	sessions := false
+	for _, e := range events {
+		if e.Timestamp != 0 {
+			sorted = append(sorted, e)
+		}
+		sessions = sessions || (e.Type == event.Session && e.Duration != 0)
+	}
+
+	// Events are logged when they finish, but stamped when they start
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp < sorted[j].Timestamp
+	})
+	start, end := int64(0), int64(0)
+	if len(sorted) > 0 {
+		start = sorted[0].Timestamp
+	}
+	for _, e := range sorted {
+		end = max(end, e.Timestamp+e.Duration)
+	}
+	micros := func(ns int64) float64 {
+		return float64(ns-start) / 1000
+	}
+
+	trace := []traceEvent{}
+	processes := map[int]string{}
+	threads := map[[2]int]string{}
+	opened := map[openFile]*event.Event{}
+
+	// track names the track of an event, and its process
+	track := func(e *event.Event, tid int, thread string) {
+		threads[[2]int{e.Pid, tid}] = thread
+		if processes[e.Pid] == "" {
+			processes[e.Pid] = processName(e, name)
+		}
+	}
+
+	// slice adds the slice of a file from its open to a time
+	slice := func(open *event.Event, until int64, closeErrno int) {
+		dur := float64(until-open.Timestamp) / 1000
+		args := map[string]any{"path": open.Path, "fd": open.Fd}
+		if open.Handle != 0 {
+			args["handle"] = open.Handle
+		}
+		if open.Type == event.Session {
+			args["bytesRead"] = open.BytesRead
+			args["bytesWritten"] = open.BytesWritten
+		}
+		if closeErrno != 0 {
+			args["closeErrno"] = event.ErrnoName(closeErrno)
+		}
+		trace = append(trace, traceEvent{
+			Name: open.Path, Cat: open.Type, Phase: "X", Ts: micros(open.Timestamp), Dur: &dur,
+			Pid: open.Pid, Tid: open.Fd, Args: args,
+		})
+		track(open, open.Fd, fmt.Sprintf("fd %d", open.Fd))
+	}
+
+	for _, e := range sorted {
+		switch e.Type {
+		case event.Lookup:
+			args := map[string]any{"path": e.Path}
+			if e.Errno != 0 {
+				args["errno"] = event.ErrnoName(e.Errno)
+			}
+			trace = append(trace, traceEvent{
+				Name: e.Path, Cat: e.Type, Phase: "i", Ts: micros(e.Timestamp), Scope: "t",
+				Pid: e.Pid, Tid: lookupTrack, Args: args,
+			})
+			track(e, lookupTrack, lookupName)
+
+		case event.Open, event.Create:
+
+			// A failed open has no file descriptor, so it is instant
+			if e.Errno != 0 || e.Fd == 0 {
+				trace = append(trace, traceEvent{
+					Name: e.Path, Cat: e.Type, Phase: "i", Ts: micros(e.Timestamp), Scope: "t",
+					Pid: e.Pid, Tid: lookupTrack, Args: map[string]any{"path": e.Path, "errno": event.ErrnoName(e.Errno)},
+				})
+				track(e, lookupTrack, lookupName)
+				continue
+			}
+			if sessions {
+				continue
+			}
+
+			// A file still open without a close ends when it is opened again
+			key := openFile{path: e.Path, fd: e.Fd, handle: e.Handle}
+			if previous, ok := opened[key]; ok {
+				slice(previous, e.Timestamp, 0)
+			}
+			opened[key] = e
+
+		case event.Close:
+
+			// A file handle can be flushed more than once, and the first ends it
+			key := openFile{path: e.Path, fd: e.Fd, handle: e.Handle}
+			if open, ok := opened[key]; ok {
+				slice(open, e.Timestamp+e.Duration, e.Errno)
+				delete(opened, key)
+			}
+
+		case event.Session:
+			if sessions {
+				slice(e, e.Timestamp+e.Duration, 0)
+			}
+		}
+	}
+
+	// Files that were never closed end with the recording
+	remaining := make([]*event.Event, 0, len(opened))
+	for _, open := range opened {
+		remaining = append(remaining, open)
+	}
+	sort.Slice(remaining, func(i, j int) bool {
+		return remaining[i].Timestamp < remaining[j].Timestamp
+	})
+	for _, open := range remaining {
+		slice(open, end, 0)
+	}
+
+	// Name the tracks, in a stable order
+	metadata := []traceEvent{}
+	for pid, process := range processes {
+		if process == "" {
+			continue
+		}
+		metadata = append(metadata, traceEvent{
+			Name: "process_name", Phase: "M", Pid: pid, Args: map[string]any{"name": process},
+		})
+	}
+	for key, thread := range threads {
+		metadata = append(metadata, traceEvent{
+			Name: "thread_name", Phase: "M", Pid: key[0], Tid: key[1], Args: map[string]any{"name": thread},
+		})
+	}
+	sort.Slice(metadata, func(i, j int) bool {
+		if metadata[i].Pid != metadata[j].Pid {
+			return metadata[i].Pid < metadata[j].Pid
+		}
+		if metadata[i].Name != metadata[j].Name {
+			return metadata[i].Name < metadata[j].Name
+		}
+		return metadata[i].Tid < metadata[j].Tid
+	})
+
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(map[string]any{
+		"traceEvents":     append(metadata, trace...),
+		"displayTimeUnit": "ms",
+	})
+}
+
+// processName names the track of a process by its command (the track
+// has the pid too), and its MPI rank
+func processName(e *event.Event, recording string) string {
+	command := e.Comm
+	if e.Pid == 0 {
+		command = recording
+	} else if command == "" {
+		command = e.Exe
+	}
+	if e.Rank != "" {
+		return fmt.Sprintf("rank %s %s", e.Rank, command)
+	}
+	return command
+}
@@ -0,0 +1,130 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// span is the begin and end (in microseconds) of a slice on a track
+type span struct {
+	path       string
+	tid        int
+	begin, end float64
+}
+
+// perfetto exports events and reads back the trace events
+func perfetto(t *testing.T, name string, events []*event.Event) []traceEvent {
+	var out bytes.Buffer
+	err := Perfetto(&out, name, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}
+	err = json.Unmarshal(out.Bytes(), &trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trace.TraceEvents
+}
+
+func TestPerfettoSlices(t *testing.T) {
+	open := func(ts int64, path string, fd int) *event.Event {
+		return &event.Event{Timestamp: ts, Type: event.Open, Path: path, Fd: fd, Pid: 7, Comm: "cat"}
+	}
+	closed := func(ts int64, path string, fd int) *event.Event {
+		return &event.Event{Timestamp: ts, Type: event.Close, Path: path, Fd: fd, Pid: 7, Duration: 500}
+	}
+
+	tests := []struct {
+		name   string
+		events []*event.Event
+		spans  []span
+	}{
+		{
+			name:   "open and close",
+			events: []*event.Event{open(1000, "/etc/hosts", 3), closed(4000, "/etc/hosts", 3)},
+			spans:  []span{{"/etc/hosts", 3, 0, 3.5}},
+		},
+		{
+			name: "descriptor reused",
+			events: []*event.Event{
+				open(1000, "/a", 3), closed(2000, "/a", 3),
+				open(3000, "/b", 3), closed(5000, "/b", 3),
+			},
+			spans: []span{{"/a", 3, 0, 1.5}, {"/b", 3, 2, 4.5}},
+		},
+		{
+			name:   "flushed twice",
+			events: []*event.Event{open(1000, "/a", 3), closed(2000, "/a", 3), closed(6000, "/a", 3)},
+			spans:  []span{{"/a", 3, 0, 1.5}},
+		},
+		{
+			name: "never closed",
+			events: []*event.Event{
+				open(1000, "/usr/lib/libc.so.6", 4),
+				{Timestamp: 9000, Type: event.Lookup, Path: "/etc", Pid: 7},
+			},
+			spans: []span{{"/usr/lib/libc.so.6", 4, 0, 8}},
+		},
+		{
+			name:   "failed open",
+			events: []*event.Event{{Timestamp: 1000, Type: event.Open, Path: "/missing", Pid: 7, Errno: 2}},
+			spans:  []span{},
+		},
+		{
+			name: "sessions",
+			events: []*event.Event{
+				open(1000, "/a", 3), closed(2000, "/a", 3),
+				{Timestamp: 1000, Type: event.Session, Path: "/a", Fd: 3, Handle: 1, Pid: 7, Duration: 2500, BytesRead: 10},
+			},
+			spans: []span{{"/a", 3, 0, 2.5}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spans := []span{}
+			for _, e := range perfetto(t, "test", test.events) {
+				if e.Phase == "X" {
+					spans = append(spans, span{e.Name, e.Tid, e.Ts, e.Ts + *e.Dur})
+				}
+			}
+			if !reflect.DeepEqual(spans, test.spans) {
+				t.Errorf("Perfetto() slices = %v, want %v", spans, test.spans)
+			}
+		})
+	}
+}
+
+func TestPerfettoTracks(t *testing.T) {
+	events := []*event.Event{
+		{Timestamp: 1000, Type: event.Lookup, Path: "/etc", Pid: 7, Comm: "cat", Rank: "2"},
+		{Timestamp: 2000, Type: event.Open, Path: "/etc/hosts", Fd: 3, Pid: 7, Comm: "cat", Rank: "2"},
+		{Timestamp: 3000, Type: event.Lookup, Path: "/etc"},
+	}
+	threads := map[[2]int]any{}
+	processes := map[int]any{}
+	for _, e := range perfetto(t, "lammps.log", events) {
+		switch e.Name {
+		case "thread_name":
+			threads[[2]int{e.Pid, e.Tid}] = e.Args["name"]
+		case "process_name":
+			processes[e.Pid] = e.Args["name"]
+		}
+	}
+
+	// Events without a process are named for the recording
+	wantThreads := map[[2]int]any{{0, lookupTrack}: lookupName, {7, lookupTrack}: lookupName, {7, 3}: "fd 3"}
+	if !reflect.DeepEqual(threads, wantThreads) {
+		t.Errorf("Perfetto() threads = %v, want %v", threads, wantThreads)
+	}
+	wantProcesses := map[int]any{0: "lammps.log", 7: "rank 2 cat"}
+	if !reflect.DeepEqual(processes, wantProcesses) {
+		t.Errorf("Perfetto() processes = %v, want %v", processes, wantProcesses)
+	}
+}
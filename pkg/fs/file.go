@@ -1,7 +1,14 @@
 package fs
 
 import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
 	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
 // The custom Wrapper file allows us to carry forward the file handle
@@ -9,6 +16,21 @@ type AllFileOps interface {
 	fs.FileReader
 	fs.FileWriter
 	fs.FileFlusher
+	fs.FileFsyncer
+	fs.FileReleaser
+}
+
+// A Recorder records events for operations on an open file
+type Recorder interface {
+	Record(ctx context.Context, e *event.Event)
+}
+
+// Finish sets when an operation started, how long it took and its result
+func Finish(e *event.Event, start time.Time, errno syscall.Errno) *event.Event {
+	e.Timestamp = start.UnixNano()
+	e.Duration = int64(time.Since(start))
+	e.Errno = int(errno)
+	return e
 }
 
 // We use this wrapperFile type to hold the file handle
@@ -16,4 +38,87 @@ type AllFileOps interface {
 type WrapperFile struct {
 	AllFileOps
 	Fid int
+
+	// Path of the file and recorder for reads, writes and fsync (optional)
+	Path     string
+	Recorder Recorder
+
+	// Handle of the open, unique in a recording, and when and by whom the
+	// file was opened. A release does not have a caller, so it is kept.
+	Handle int64
+	Opened time.Time
+	Caller *fuse.Caller
+
+	// Bytes read and written through the handle
+	bytesRead    int64
+	bytesWritten int64
+}
+
+// Event returns an event for an operation on the file
+func (f *WrapperFile) Event(eventType string) *event.Event {
+	return &event.Event{Type: eventType, Path: f.Path, Fd: f.Fid, Handle: f.Handle}
+}
+
+// Read records the offset and bytes read
+func (f *WrapperFile) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	start := time.Now()
+	result, errno := f.AllFileOps.Read(ctx, dest, off)
+	size := int64(0)
+	if errno == 0 {
+		size = int64(result.Size())
+		atomic.AddInt64(&f.bytesRead, size)
+	}
+	if f.Recorder != nil {
+		e := f.Event(event.Read)
+		e.Offset = off
+		e.Size = size
+		f.Recorder.Record(ctx, Finish(e, start, errno))
+	}
+	return result, errno
+}
+
+// Write records the offset and bytes written
+func (f *WrapperFile) Write(ctx context.Context, data []byte, off int64) (uint32, syscall.Errno) {
+	start := time.Now()
+	written, errno := f.AllFileOps.Write(ctx, data, off)
+	atomic.AddInt64(&f.bytesWritten, int64(written))
+	if f.Recorder != nil {
+		e := f.Event(event.Write)
+		e.Offset = off
+		e.Size = int64(written)
+		f.Recorder.Record(ctx, Finish(e, start, errno))
+	}
+	return written, errno
+}
+
+// Fsync records a sync of the file
+func (f *WrapperFile) Fsync(ctx context.Context, flags uint32) syscall.Errno {
+	start := time.Now()
+	errno := f.AllFileOps.Fsync(ctx, flags)
+	if f.Recorder != nil {
+		f.Recorder.Record(ctx, Finish(f.Event(event.Fsync), start, errno))
+	}
+	return errno
+}
+
+// Release closes the file when the last reference to the handle is
+// closed, and records the release and a session of the handle, from its
+// open to its release, with the bytes read and written
+func (f *WrapperFile) Release(ctx context.Context) syscall.Errno {
+	start := time.Now()
+	errno := f.AllFileOps.Release(ctx)
+	if f.Recorder == nil {
+		return errno
+	}
+	if f.Caller != nil {
+		ctx = fuse.NewContext(ctx, f.Caller)
+	}
+	f.Recorder.Record(ctx, Finish(f.Event(event.Release), start, errno))
+	session := f.Event(event.Session)
+	session.Timestamp = f.Opened.UnixNano()
+	session.Duration = int64(time.Since(f.Opened))
+	session.BytesRead = atomic.LoadInt64(&f.bytesRead)
+	session.BytesWritten = atomic.LoadInt64(&f.bytesWritten)
+	f.Recorder.Record(ctx, session)
+	return errno
 }
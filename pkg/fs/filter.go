@@ -0,0 +1,48 @@
+package fs
+
+import (
+	"slices"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+// A Filter selects the events to record by caller. An event is kept if
+// it matches every criteria that is set.
+type Filter struct {
+
+	// Processes (and their descendants) to record
+	Pids []int
+
+	// Users to record
+	Uids []int
+
+	// Command names to record
+	Comms []string
+}
+
+// Empty determines if the filter keeps all events
+func (f *Filter) Empty() bool {
+	return f == nil || (len(f.Pids) == 0 && len(f.Uids) == 0 && len(f.Comms) == 0)
+}
+
+// Match determines if an event (with a caller) passes the filter
+func (f *Filter) Match(e *event.Event, processes *ProcessCache) bool {
+	if f.Empty() {
+		return true
+	}
+	if len(f.Uids) > 0 && !slices.Contains(f.Uids, e.Uid) {
+		return false
+	}
+	if len(f.Comms) > 0 && !slices.Contains(f.Comms, e.Comm) {
+		return false
+	}
+	if len(f.Pids) > 0 && !slices.Contains(f.Pids, e.Pid) {
+		for _, ancestor := range processes.Ancestors(e.Pid) {
+			if slices.Contains(f.Pids, ancestor) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
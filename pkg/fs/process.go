@@ -0,0 +1,173 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	"github.com/compspec/compat-lib/pkg/mpi"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// A Process is a caller of the filesystem, resolved with /proc
+type Process struct {
+	Pid  int
+	Ppid int
+	Comm string
+	Exe  string
+
+	// Start time (in clock ticks after boot), which tells a reused pid apart
+	Start uint64
+
+	// MPI rank, from the environment (if ranks are read)
+	Rank string
+
+	// When the process was read
+	read time.Time
+}
+
+// How long a cached process is used before it is read again (for a pid
+// that was reused, or an exec of a program outside the filesystem)
+const processTTL = time.Second
+
+// Flag of an open by exec, of the program or its interpreter (__FMODE_EXEC)
+const OpenExec = 0x20
+
+// ProcessCache resolves processes by pid, and caches them per pid. The first
+// request of a process is often before it runs its program (e.g., after the
+// fork of a launcher), so a process is read again after it opens a program
+// to exec it.
+type ProcessCache struct {
+	mutex     sync.Mutex
+	processes map[int]*Process
+
+	// Read the MPI rank of processes
+	Ranks bool
+
+	// Reads a process from /proc
+	read func(pid int) *Process
+}
+
+// NewProcessCache returns an empty process cache
+func NewProcessCache() *ProcessCache {
+	return &ProcessCache{processes: map[int]*Process{}, read: readProcess}
+}
+
+// Get returns a process by pid. A process that has already exited
+// has what was last read (or only the pid).
+func (c *ProcessCache) Get(pid int) *Process {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.get(pid)
+}
+
+func (c *ProcessCache) get(pid int) *Process {
+	cached, ok := c.processes[pid]
+	if ok && time.Since(cached.read) < processTTL {
+		return cached
+	}
+	process := c.read(pid)
+	process.read = time.Now()
+	if ok && process.Start == 0 {
+		cached.read = process.read
+		return cached
+	}
+
+	// The environment (and so the rank) only changes with an exec
+	if ok && cached.Start == process.Start && cached.Exe == process.Exe {
+		process.Rank = cached.Rank
+	} else if c.Ranks {
+		process.Rank = mpi.ProcessRank(pid)
+	}
+	c.processes[pid] = process
+	return process
+}
+
+// Exec marks the caller of an open by exec (of a program or its interpreter)
+// to be read again on its next request. The exec has not replaced the process
+// when it opens these, so the process is read once the program runs.
+func (c *ProcessCache) Exec(ctx context.Context, flags uint32) {
+	caller, ok := fuse.FromContext(ctx)
+	if !ok || flags&OpenExec == 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if process, ok := c.processes[int(caller.Pid)]; ok {
+		process.read = time.Time{}
+	}
+}
+
+// Ancestors returns the parent, grandparent, etc. of a process
+func (c *ProcessCache) Ancestors(pid int) []int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ancestors := []int{}
+	seen := map[int]bool{pid: true}
+	for ppid := c.get(pid).Ppid; ppid > 0 && !seen[ppid]; ppid = c.get(ppid).Ppid {
+		ancestors = append(ancestors, ppid)
+		seen[ppid] = true
+	}
+	return ancestors
+}
+
+// Annotate adds the caller of a request (pid, uid, gid, and process
+// details, with the rank if ranks are read) to an event
+func (c *ProcessCache) Annotate(ctx context.Context, e *event.Event) {
+	caller, ok := fuse.FromContext(ctx)
+	if !ok {
+		return
+	}
+	e.Pid = int(caller.Pid)
+	e.Uid = int(caller.Uid)
+	e.Gid = int(caller.Gid)
+	if e.Pid == 0 {
+		return
+	}
+	process := c.Get(e.Pid)
+	e.Ppid = process.Ppid
+	e.Comm = process.Comm
+	e.Exe = process.Exe
+	e.Rank = process.Rank
+}
+
+// readProcess reads the parent, command, start time, and executable of a pid
+func readProcess(pid int) *Process {
+	process := Process{Pid: pid}
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err == nil {
+		process.Comm, process.Ppid, process.Start = parseStat(string(stat))
+	}
+	exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	if err == nil {
+		process.Exe = exe
+	}
+	return &process
+}
+
+// parseStat parses the command, parent, and start time from
+// /proc/<pid>/stat. The command is in parentheses and can have spaces (or
+// parentheses), and the fields after it start with the state (field 3).
+func parseStat(stat string) (string, int, uint64) {
+	open := strings.Index(stat, "(")
+	end := strings.LastIndex(stat, ")")
+	if open < 0 || end < open {
+		return "", 0, 0
+	}
+	comm := stat[open+1 : end]
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 2 {
+		return comm, 0, 0
+	}
+	ppid, _ := strconv.Atoi(fields[1])
+	if len(fields) < 20 {
+		return comm, ppid, 0
+	}
+	start, _ := strconv.ParseUint(fields[19], 10, 64)
+	return comm, ppid, start
+}
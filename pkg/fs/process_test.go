@@ -0,0 +1,120 @@
+package fs
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// fakeProc is a process table that counts the reads of each pid
+type fakeProc struct {
+	processes map[int]Process
+	reads     map[int]int
+}
+
+func (f *fakeProc) read(pid int) *Process {
+	f.reads[pid]++
+	process := f.processes[pid]
+	process.Pid = pid
+	return &process
+}
+
+func TestProcessCacheGet(t *testing.T) {
+	caller := fuse.NewContext(context.Background(), &fuse.Caller{Owner: fuse.Owner{Uid: 1000, Gid: 1000}, Pid: 20})
+	cat := Process{Ppid: 10, Comm: "cat", Exe: "/usr/bin/cat", Start: 150}
+
+	tests := []struct {
+		name string
+
+		// Done between the two requests
+		between func(c *ProcessCache, proc *fakeProc)
+		reads   int
+		comm    string
+	}{
+		{name: "cached", reads: 1, comm: "sh"},
+		{
+			name:  "exec",
+			reads: 2,
+			comm:  "cat",
+			between: func(c *ProcessCache, proc *fakeProc) {
+				proc.processes[20] = cat
+				c.Exec(caller, syscall.O_RDONLY|OpenExec)
+			},
+		},
+		{
+			name:  "open of a program to read it",
+			reads: 1,
+			comm:  "sh",
+			between: func(c *ProcessCache, proc *fakeProc) {
+				proc.processes[20] = cat
+				c.Exec(caller, syscall.O_RDONLY)
+			},
+		},
+		{
+			name:  "expired",
+			reads: 2,
+			comm:  "cat",
+			between: func(c *ProcessCache, proc *fakeProc) {
+				proc.processes[20] = cat
+				c.processes[20].read = time.Now().Add(-processTTL)
+			},
+		},
+		{
+			name:  "exited",
+			reads: 2,
+			comm:  "sh",
+			between: func(c *ProcessCache, proc *fakeProc) {
+				delete(proc.processes, 20)
+				c.processes[20].read = time.Time{}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proc := &fakeProc{
+				processes: map[int]Process{20: {Ppid: 10, Comm: "sh", Exe: "/usr/bin/sh", Start: 150}},
+				reads:     map[int]int{},
+			}
+			c := NewProcessCache()
+			c.read = proc.read
+			c.Get(20)
+			if test.between != nil {
+				test.between(c, proc)
+			}
+			process := c.Get(20)
+			if proc.reads[20] != test.reads {
+				t.Errorf("Get() read /proc %d times, want %d", proc.reads[20], test.reads)
+			}
+			if process.Comm != test.comm {
+				t.Errorf("Get() = %q, want %q", process.Comm, test.comm)
+			}
+		})
+	}
+}
+
+func TestParseStat(t *testing.T) {
+	stat := func(comm string) string {
+		return "4242 (" + comm + ") S 4200 4242 4200 34816 4242 4194304 92 0 0 0 0 0 0 0 20 0 1 0 123456 8790016 448"
+	}
+	tests := []struct {
+		stat  string
+		comm  string
+		ppid  int
+		start uint64
+	}{
+		{stat("cat"), "cat", 4200, 123456},
+		{stat("Web Content"), "Web Content", 4200, 123456},
+		{stat("a) S 1 (b"), "a) S 1 (b", 4200, 123456},
+		{"4242 (cat) S 4200", "cat", 4200, 0},
+		{"4242 cat", "", 0, 0},
+	}
+	for _, test := range tests {
+		comm, ppid, start := parseStat(test.stat)
+		if comm != test.comm || ppid != test.ppid || start != test.start {
+			t.Errorf("parseStat(%q) = %q, %d, %d, want %q, %d, %d", test.stat, comm, ppid, start, test.comm, test.ppid, test.start)
+		}
+	}
+}
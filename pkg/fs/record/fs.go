@@ -6,23 +6,56 @@ import (
 	"os/exec"
 	"strings"
 
+	"github.com/compspec/compat-lib/pkg/event"
 	defaults "github.com/compspec/compat-lib/pkg/fs"
 	"github.com/compspec/compat-lib/pkg/logger"
 	"github.com/google/shlex"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
-// Generic update type to be parsed later
-type Update struct {
-	Message string
-}
-
 type RecordFS struct {
 	Server     *fuse.Server
 	MountPoint string
 
-	// Output file, if defined, to save events
+	// Output file or session directory, if defined, to save events
 	Outfile string
+	Logger  logger.EventLogger
+
+	// Callers of the filesystem, and the filter for events by caller
+	Processes *defaults.ProcessCache
+	Filter    *defaults.Filter
+
+	// Types of events to record
+	Events event.Mask
+
+	// Counts and latency of the recorded events
+	Summary *event.Summary
+
+	// Subscribers to recorded events (e.g., a stream server)
+	Stream *event.Broadcaster
+
+	// MPI rank for events without one (empty without MPI)
+	Rank string
+
+	// Last handle given to an open file
+	handles int64
+}
+
+// Options for what a RecordFS records
+type Options struct {
+
+	// Only record events from callers that pass the filter (nil for all)
+	Filter *defaults.Filter
+
+	// Types of events to record (nil for all)
+	Events event.Mask
+
+	// Where to save events (nil to not save them)
+	Logger logger.EventLogger
+
+	// MPI rank of the recorder (empty without MPI). Events are tagged
+	// with the rank of their process, or this rank if it has none.
+	Rank string
 }
 
 // Cleanup removes the mountpoint directory
@@ -31,9 +64,8 @@ func (rfs *RecordFS) Cleanup() {
 	// Clean up mount point directory
 	fmt.Printf("Cleaning up %s...\n", rfs.MountPoint)
 	os.RemoveAll(rfs.MountPoint)
-	if logger.Outfile != "" {
-		fmt.Printf("Output file written to %s\n", logger.Outfile)
-		os.Chmod(logger.Outfile, 0644)
+	if rfs.Outfile != "" {
+		fmt.Printf("Output written to %s\n", rfs.Outfile)
 	}
 }
 
@@ -61,18 +93,29 @@ func isMounted(path string) (bool, error) {
 // correctly handled - see how it is used here in the library
 // If recorder is true, we instantiate a recording base
 // If skip creation is true, we assume another process
-// has created it.
+// has created it. The options select the events and callers
+// to record, and where to save them.
 func NewRecordFS(
 	mountPath string,
-	recordFile string,
 	readOnly bool,
+	options Options,
 ) (*RecordFS, error) {
 
 	// Create a Compat Filesystem with defaults
-	rfs := RecordFS{Outfile: logger.Outfile}
+	rfs := RecordFS{
+		Logger:    options.Logger,
+		Processes: defaults.NewProcessCache(),
+		Filter:    options.Filter,
+		Events:    options.Events,
+		Summary:   event.NewSummary(),
+		Stream:    event.NewBroadcaster(),
+		Rank:      options.Rank,
+	}
+	rfs.Processes.Ranks = options.Rank != ""
 
-	// Set the global log file in case we are recording events
-	logger.SetOutfile(recordFile)
+	if rfs.Logger != nil {
+		rfs.Outfile = rfs.Logger.Path()
+	}
 
 	// TODO keep track of cpu and memory profiles
 	if mountPath == "" {
@@ -86,14 +129,8 @@ func NewRecordFS(
 	// One more check if directory doesn't exist
 	_, err := os.Stat(mountPath)
 	if err != nil && os.IsNotExist(err) {
-		err := os.Mkdir(mountPath, 0755)
-		if err != nil {
-			return nil, err
-		}
+		err = os.Mkdir(mountPath, 0755)
 	}
-	// Create generic updates channel
-	updates := make(chan Update)
-
 	fmt.Printf("Mount directory %s\n", mountPath)
 	rfs.MountPoint = mountPath
 	if err != nil {
@@ -106,12 +143,10 @@ func NewRecordFS(
 	}
 
 	// Mount the content of the rootFS (originalFS) at the mount point
-	// Pass in a channel to receive updates from
 	if !alreadyMounted {
 		err = rfs.InitLoopbackRoot(
 			defaults.OriginalFS,
 			mountPath,
-			updates,
 			readOnly,
 		)
 		if err != nil {
@@ -7,13 +7,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
 
 	defaults "github.com/compspec/compat-lib/pkg/fs"
 
-	"github.com/compspec/compat-lib/pkg/logger"
+	"github.com/compspec/compat-lib/pkg/event"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
@@ -22,9 +23,20 @@ import (
 var _ = (fs.NodeOpener)((*LoopbackNode)(nil))
 var _ = (fs.NodeLookuper)((*LoopbackNode)(nil))
 var _ = (fs.NodeFlusher)((*LoopbackNode)(nil))
+var _ = (fs.NodeReadlinker)((*LoopbackNode)(nil))
+var _ = (fs.NodeGetattrer)((*LoopbackNode)(nil))
+var _ = (fs.NodeOpendirHandler)((*LoopbackNode)(nil))
+var _ = (fs.NodeGetxattrer)((*LoopbackNode)(nil))
+var _ = (fs.NodeListxattrer)((*LoopbackNode)(nil))
+var _ = (fs.NodeUnlinker)((*LoopbackNode)(nil))
+var _ = (fs.NodeRenamer)((*LoopbackNode)(nil))
+var _ = (fs.NodeMkdirer)((*LoopbackNode)(nil))
 
 type LoopbackNode struct {
 	fs.LoopbackNode
+
+	// The filesystem that records events
+	rfs *RecordFS
 }
 
 func (n *LoopbackNode) path() string {
@@ -35,16 +47,20 @@ func (n *LoopbackNode) path() string {
 func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	p := filepath.Join(n.path(), name)
 	st := syscall.Stat_t{}
+	start := time.Now()
 	err := syscall.Lstat(p, &st)
+
+	// Failed lookups are recorded too, e.g., probing a library search path
+	errno := fs.ToErrno(err)
+	n.rfs.Record(ctx, defaults.Finish(&event.Event{Type: event.Lookup, Path: p, Size: st.Size}, start, errno))
 	if err != nil {
-		return nil, fs.ToErrno(err)
+		return nil, errno
 	}
 	// Stat has the following:
 	// Dev     Ino      Nlink Mode  Uid Gid X_pad Rdev Size  Blksize Blocks  Atim          Mtim           Ctim                   X_unused
 	//{2097217 13408317 1     41471 0   0   0     0    18    4096    0      {1633012128 0} {1633012128 0} {1732061992 277100520} [0 0 0]}
-	logger.LogEvent("Lookup", p)
 	out.Attr.FromStat(&st)
-	node := newNode(n.RootData, n.EmbeddedInode(), name, &st)
+	node := n.rfs.newNode(n.RootData, n.EmbeddedInode(), name, &st)
 	ch := n.NewInode(ctx, node, idFromStat(n.RootData, &st))
 	return ch, 0
 }
@@ -60,9 +76,12 @@ func (n *LoopbackNode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errn
 	wf, ok := fh.(*defaults.WrapperFile)
 	if !ok {
 		fmt.Printf("Warning: cannot serialize %s back to wrapped file, this should not happen\n", p)
+		return 0
 	}
-	logger.LogEvent("Close", fmt.Sprintf("%s\t%d", p, wf.Fid))
-	return 0
+	start := time.Now()
+	errno := wf.Flush(ctx)
+	n.rfs.Record(ctx, defaults.Finish(wf.Event(event.Close), start, errno))
+	return errno
 }
 
 // https://github.com/hanwen/go-fuse/blob/f5b6d1b67f4a4d0f4c3c88b4491185b3685e8383/fs/loopback.go#L48
@@ -97,45 +116,232 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, u
 	// This next section emulates:
 	// 	fh, flags, errno := n.LoopbackNode.Open(ctx, flags)
 	// But we unwrap to get the fd (file descriptor) to uniquely identify
+	start := time.Now()
 	fd, err := syscall.Open(p, int(flags), 0)
 	if err != nil {
-		return nil, 0, fs.ToErrno(err)
+		errno := fs.ToErrno(err)
+		n.rfs.Record(ctx, defaults.Finish(&event.Event{Type: event.Open, Path: p}, start, errno))
+		return nil, 0, errno
 	}
-	logger.LogEvent("Open", fmt.Sprintf("%s\t%d", p, fd))
 	loopbackFile := fs.NewLoopbackFile(fd)
-	fh := &defaults.WrapperFile{
-		AllFileOps: loopbackFile.(defaults.AllFileOps),
-		Fid:        fd,
-	}
+	fh := n.rfs.newFile(ctx, loopbackFile, fd, p, start)
+	n.rfs.Record(ctx, defaults.Finish(fh.Event(event.Open), start, 0))
+	n.rfs.Processes.Exec(ctx, flags)
 
 	// fh, flags, errno
 	return fh, 0, 0
 }
 
+// Create wraps the new file so reads, writes and close are recorded
 func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
-	logger.LogEvent("Create", name)
+	p := filepath.Join(n.path(), name)
+	start := time.Now()
 	inode, fh, flags, errno := n.LoopbackNode.Create(ctx, name, flags, mode, out)
-	return inode, fh, flags, errno
+	if errno != 0 {
+		n.rfs.Record(ctx, defaults.Finish(&event.Event{Type: event.Create, Path: p}, start, errno))
+		return inode, fh, flags, errno
+	}
+	fd := GetUnexportedField(reflect.ValueOf(fh).Elem().FieldByName("fd")).(int)
+	wf := n.rfs.newFile(ctx, fh, fd, p, start)
+	n.rfs.Record(ctx, defaults.Finish(wf.Event(event.Create), start, 0))
+	return inode, wf, flags, errno
+}
+
+func (n *LoopbackNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
+	start := time.Now()
+	target, errno := n.LoopbackNode.Readlink(ctx)
+	e := &event.Event{Type: event.Readlink, Path: n.path(), Target: string(target)}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return target, errno
+}
+
+func (n *LoopbackNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	start := time.Now()
+	errno := n.LoopbackNode.Getattr(ctx, fh, out)
+	e := &event.Event{Type: event.Getattr, Path: n.path()}
+	if errno == 0 {
+		e.Size = int64(out.Size)
+	}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return errno
+}
+
+// OpendirHandle records opening a directory, and wraps the stream to
+// record the entries read from it
+func (n *LoopbackNode) OpendirHandle(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	p := n.path()
+	start := time.Now()
+	fh, fuseFlags, errno := n.LoopbackNode.OpendirHandle(ctx, flags)
+	n.rfs.Record(ctx, defaults.Finish(&event.Event{Type: event.Opendir, Path: p}, start, errno))
+	if errno != 0 {
+		return fh, fuseFlags, errno
+	}
+	dir, ok := fh.(dirHandle)
+	if !ok {
+		return fh, fuseFlags, errno
+	}
+	caller, _ := fuse.FromContext(ctx)
+	return &recordDir{dirHandle: dir, path: p, rfs: n.rfs, caller: caller}, fuseFlags, errno
+}
+
+func (n *LoopbackNode) Getxattr(ctx context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	start := time.Now()
+	size, errno := n.LoopbackNode.Getxattr(ctx, attr, dest)
+	e := &event.Event{Type: event.Getxattr, Path: n.path(), Target: attr, Size: int64(size)}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return size, errno
+}
+
+func (n *LoopbackNode) Listxattr(ctx context.Context, dest []byte) (uint32, syscall.Errno) {
+	start := time.Now()
+	size, errno := n.LoopbackNode.Listxattr(ctx, dest)
+	e := &event.Event{Type: event.Listxattr, Path: n.path(), Size: int64(size)}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return size, errno
+}
+
+func (n *LoopbackNode) Unlink(ctx context.Context, name string) syscall.Errno {
+	start := time.Now()
+	errno := n.LoopbackNode.Unlink(ctx, name)
+	e := &event.Event{Type: event.Unlink, Path: filepath.Join(n.path(), name)}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return errno
+}
+
+func (n *LoopbackNode) Rename(ctx context.Context, name string, newParent fs.InodeEmbedder, newName string, flags uint32) syscall.Errno {
+	start := time.Now()
+	errno := n.LoopbackNode.Rename(ctx, name, newParent, newName, flags)
+	target := filepath.Join(n.RootData.Path, newParent.EmbeddedInode().Path(nil), newName)
+	e := &event.Event{Type: event.Rename, Path: filepath.Join(n.path(), name), Target: target}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return errno
+}
+
+func (n *LoopbackNode) Mkdir(ctx context.Context, name string, mode uint32, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	start := time.Now()
+	inode, errno := n.LoopbackNode.Mkdir(ctx, name, mode, out)
+	e := &event.Event{Type: event.Mkdir, Path: filepath.Join(n.path(), name)}
+	n.rfs.Record(ctx, defaults.Finish(e, start, errno))
+	return inode, errno
 }
 
-func newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
+// The operations of a loopback directory stream
+type dirHandle interface {
+	fs.FileReaddirenter
+	fs.FileSeekdirer
+	fs.FileReleasedirer
+	fs.FileFsyncdirer
+}
+
+// recordDir counts the entries read from a directory, and records
+// them when the directory is released (if any were read, or reading
+// failed). A release does not have a caller, so the caller that opened the
+// directory is kept. The duration is the time spent reading entries, and
+// the errno the last failure. A rewind starts the count over, so entries
+// read again are not counted twice.
+type recordDir struct {
+	dirHandle
+	path     string
+	rfs      *RecordFS
+	caller   *fuse.Caller
+	entries  int64
+	duration int64
+	errno    int64
+}
+
+func (d *recordDir) Readdirent(ctx context.Context) (*fuse.DirEntry, syscall.Errno) {
+	start := time.Now()
+	entry, errno := d.dirHandle.Readdirent(ctx)
+	atomic.AddInt64(&d.duration, int64(time.Since(start)))
+	if entry != nil {
+		atomic.AddInt64(&d.entries, 1)
+	}
+	if errno != 0 {
+		atomic.StoreInt64(&d.errno, int64(errno))
+	}
+	return entry, errno
+}
+
+func (d *recordDir) Seekdir(ctx context.Context, off uint64) syscall.Errno {
+	errno := d.dirHandle.Seekdir(ctx, off)
+	if errno == 0 && off == 0 {
+		atomic.StoreInt64(&d.entries, 0)
+	}
+	return errno
+}
+
+func (d *recordDir) Releasedir(ctx context.Context, flags uint32) {
+	if d.caller != nil {
+		ctx = fuse.NewContext(ctx, d.caller)
+	}
+	entries, errno := atomic.LoadInt64(&d.entries), int(atomic.LoadInt64(&d.errno))
+	if entries > 0 || errno != 0 {
+		d.rfs.Record(ctx, &event.Event{
+			Type:     event.Readdir,
+			Path:     d.path,
+			Size:     entries,
+			Duration: atomic.LoadInt64(&d.duration),
+			Errno:    errno,
+		})
+	}
+	d.dirHandle.Releasedir(ctx, flags)
+}
+
+func (rfs *RecordFS) newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
 	n := &LoopbackNode{
 		LoopbackNode: fs.LoopbackNode{
 			RootData: rootData,
 		},
+		rfs: rfs,
 	}
 	return n
 }
 
+// newFile wraps an open file to record operations on it, with a new handle
+func (rfs *RecordFS) newFile(ctx context.Context, fh fs.FileHandle, fd int, path string, opened time.Time) *defaults.WrapperFile {
+	caller, _ := fuse.FromContext(ctx)
+	return &defaults.WrapperFile{
+		AllFileOps: fh.(defaults.AllFileOps),
+		Fid:        fd,
+		Path:       path,
+		Recorder:   rfs,
+		Handle:     atomic.AddInt64(&rfs.handles, 1),
+		Opened:     opened,
+		Caller:     caller,
+	}
+}
+
+// Record adds the caller to an event, logs it and sends it to subscribers,
+// unless the event type is not in the mask or the caller is filtered
+func (rfs *RecordFS) Record(ctx context.Context, e *event.Event) {
+	if !rfs.Events.Has(e.Type) {
+		return
+	}
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().UnixNano()
+	}
+	rfs.Processes.Annotate(ctx, e)
+	if !rfs.Filter.Match(e, rfs.Processes) {
+		return
+	}
+	if e.Rank == "" {
+		e.Rank = rfs.Rank
+	}
+	rfs.Summary.Add(e)
+	if rfs.Logger != nil {
+		rfs.Logger.LogEvent(e)
+	}
+	rfs.Stream.Publish(e)
+}
+
 // InitLoopbackRoot creates a fuse.Server
 func (rfs *RecordFS) InitLoopbackRoot(
 	rootPath, mountPoint string,
-	updates chan Update,
 	readOnly bool,
 ) error {
 
 	rootData := &fs.LoopbackRoot{
-		NewNode: newNode,
+		NewNode: rfs.newNode,
 		Path:    rootPath,
 	}
 
@@ -170,7 +376,7 @@ func (rfs *RecordFS) InitLoopbackRoot(
 	}
 
 	// This is  going to block
-	server, err := fs.Mount(mountPoint, newNode(rootData, nil, "", nil), options)
+	server, err := fs.Mount(mountPoint, rfs.newNode(rootData, nil, "", nil), options)
 	rfs.Server = server
 	return err
 }
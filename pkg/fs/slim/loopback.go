@@ -11,6 +11,7 @@ import (
 
 	defaults "github.com/compspec/compat-lib/pkg/fs"
 
+	"github.com/compspec/compat-lib/pkg/event"
 	"github.com/compspec/compat-lib/pkg/utils"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -24,6 +25,9 @@ var _ = (fs.NodeReadlinker)((*LoopbackNode)(nil))
 
 type LoopbackNode struct {
 	fs.LoopbackNode
+
+	// The filesystem that logs events
+	sfs *SlimFS
 }
 
 func (n *LoopbackNode) path() string {
@@ -40,6 +44,7 @@ func (n *LoopbackNode) cachePath() string {
 // Lookup is the event when a path is being looked for. When it is found, then we see open.
 func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	originalPath := filepath.Join(n.path(), name)
+	n.sfs.Record(&event.Event{Type: event.Lookup, Path: originalPath})
 	st := syscall.Stat_t{}
 	err := syscall.Lstat(originalPath, &st)
 	if err != nil {
@@ -62,13 +67,14 @@ func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryO
 		}
 	}*/
 	out.Attr.FromStat(&st)
-	node := newNode(n.RootData, n.EmbeddedInode(), name, &st)
+	node := n.sfs.newNode(n.RootData, n.EmbeddedInode(), name, &st)
 	ch := n.NewInode(ctx, node, idFromStat(n.RootData, &st))
 	return ch, 0
 }
 
 func (n *LoopbackNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
 	p := n.path()
+	n.sfs.Record(&event.Event{Type: event.Readlink, Path: p})
 
 	for l := 256; ; l *= 2 {
 		buf := make([]byte, l)
@@ -86,6 +92,13 @@ func (n *LoopbackNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
 // Flush is called for the close(2) call, could be multiple times. See:
 // https://github.com/hanwen/go-fuse/blob/aff07cbd88fef6a2561a87a1e43255516ba7d4b6/fs/api.go#L369
 func (n *LoopbackNode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errno {
+	p := n.path()
+	wf, ok := fh.(*defaults.WrapperFile)
+	if !ok {
+		fmt.Printf("Warning: cannot serialize %s back to wrapped file, this should not happen\n", p)
+		return 0
+	}
+	n.sfs.Record(&event.Event{Type: event.Close, Path: p, Fd: wf.Fid})
 	return 0
 }
 
@@ -125,6 +138,7 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, u
 		utils.CopyFile(originalPath, cachePath)
 		cache[originalPath] = cachePath
 	}
+	n.sfs.Record(&event.Event{Type: event.Open, Path: cachePath})
 
 	// This next section emulates:
 	// 	fh, flags, errno := n.LoopbackNode.Open(ctx, flags)
@@ -145,23 +159,33 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, u
 }
 
 func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
+	n.sfs.Record(&event.Event{Type: event.Create, Path: name})
 	inode, fh, flags, errno := n.LoopbackNode.Create(ctx, name, flags, mode, out)
 	return inode, fh, flags, errno
 }
 
-func newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
+func (sfs *SlimFS) newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
 	n := &LoopbackNode{
 		LoopbackNode: fs.LoopbackNode{
 			RootData: rootData,
 		},
+		sfs: sfs,
 	}
 	return n
 }
 
+// Record logs an event and sends it to subscribers
+func (sfs *SlimFS) Record(e *event.Event) {
+	e.Timestamp = time.Now().UnixNano()
+	if sfs.Logger != nil {
+		sfs.Logger.LogEvent(e)
+	}
+	sfs.Stream.Publish(e)
+}
+
 // InitLoopbackRoot creates a fuse.Server
 func (sfs *SlimFS) InitLoopbackRoot(
 	rootPath string,
-	updates chan Update,
 	readOnly bool,
 ) error {
 
@@ -203,13 +227,13 @@ func (sfs *SlimFS) InitLoopbackRoot(
 	}
 
 	rootData := &fs.LoopbackRoot{
-		NewNode: newNode,
+		NewNode: sfs.newNode,
 		Path:    rootPath,
 		Dev:     uint64(st.Dev),
 	}
 
 	// This is going to block
-	server, err := fs.Mount(sfs.RootFS(), newNode(rootData, nil, "", &st), options)
+	server, err := fs.Mount(sfs.RootFS(), sfs.newNode(rootData, nil, "", &st), options)
 	sfs.Server = server
 	return err
 }
@@ -6,17 +6,13 @@ import (
 	"os/exec"
 	"path/filepath"
 
+	"github.com/compspec/compat-lib/pkg/event"
 	defaults "github.com/compspec/compat-lib/pkg/fs"
 	"github.com/compspec/compat-lib/pkg/logger"
 	"github.com/google/shlex"
 	"github.com/hanwen/go-fuse/v2/fuse"
 )
 
-// Generic update type to be parsed later
-type Update struct {
-	Message string
-}
-
 // Mock a cache, this is in memory to reflect the filesystem
 // Once a record is added here, it's assumed present in <mountRoot>/cache/<path>
 var cache = map[string]string{}
@@ -31,6 +27,10 @@ type SpindleFS struct {
 
 	// Output file, if defined, to save events
 	Outfile string
+	Logger  logger.EventLogger
+
+	// Subscribers to events (e.g., a stream server)
+	Stream *event.Broadcaster
 }
 
 // RootFS returns the path in the root under the mountpoint
@@ -57,9 +57,8 @@ func (sfs *SpindleFS) Cleanup(keepCache bool) {
 	}
 
 	// Change permissions on output file
-	if logger.Outfile != "" {
-		fmt.Printf("Output file written to %s\n", logger.Outfile)
-		os.Chmod(logger.Outfile, 0644)
+	if sfs.Outfile != "" {
+		fmt.Printf("Output file written to %s\n", sfs.Outfile)
 	}
 }
 
@@ -75,15 +74,15 @@ func (sfs *SpindleFS) MountedPath(path string) string {
 // If recorder is true, we instantiate a recording base
 func NewSpindleFS(
 	mountPath string,
-	recordFile string,
+	events logger.EventLogger,
 	readOnly bool,
 ) (*SpindleFS, error) {
 
 	// Create a Compat Filesystem with defaults
-	sfs := SpindleFS{Outfile: logger.Outfile}
-
-	// Set the global log file in case we are recording events
-	logger.SetOutfile(recordFile)
+	sfs := SpindleFS{Logger: events, Stream: event.NewBroadcaster()}
+	if events != nil {
+		sfs.Outfile = events.Path()
+	}
 
 	// TODO keep track of cpu and memory profiles
 	if mountPath == "" {
@@ -107,17 +106,13 @@ func NewSpindleFS(
 		}
 	}
 
-	// Create generic updates channel (this will eventually
-	// be used for the service) and init faux cache
-	updates := make(chan Update)
+	// Init faux cache
 	cache = make(map[string]string)
 	fmt.Printf("Mount directory %s\n", mountPath)
 
 	// Mount the content of the rootFS (originalFS) at the mount point
-	// Pass in a channel to receive updates from
 	err := sfs.InitLoopbackRoot(
 		defaults.OriginalFS,
-		updates,
 		readOnly,
 	)
 	if err != nil {
@@ -11,7 +11,7 @@ import (
 
 	defaults "github.com/compspec/compat-lib/pkg/fs"
 
-	"github.com/compspec/compat-lib/pkg/logger"
+	"github.com/compspec/compat-lib/pkg/event"
 	"github.com/compspec/compat-lib/pkg/utils"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
@@ -25,6 +25,9 @@ var _ = (fs.NodeReadlinker)((*LoopbackNode)(nil))
 
 type LoopbackNode struct {
 	fs.LoopbackNode
+
+	// The filesystem that logs events
+	sfs *SpindleFS
 }
 
 func (n *LoopbackNode) path() string {
@@ -41,10 +44,9 @@ func (n *LoopbackNode) cachePath() string {
 // Lookup is the event when a path is being looked for. When it is found, then we see open.
 func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	p := filepath.Join(n.path(), name)
-	logger.LogEvent("Lookup", p)
+	n.sfs.Record(&event.Event{Type: event.Lookup, Path: p})
 	st := syscall.Stat_t{}
 	err := syscall.Lstat(p, &st)
-	//	logger.LogEvent("Lookup", p)
 	if err != nil {
 		return nil, fs.ToErrno(err)
 	}
@@ -59,14 +61,14 @@ func (n *LoopbackNode) Lookup(ctx context.Context, name string, out *fuse.EntryO
 	//{2097217 13408317 1     41471 0   0   0     0    18    4096    0      {1633012128 0} {1633012128 0} {1732061992 277100520} [0 0 0]}
 	//fmt.Printf("LookupFound %s %d\n", p, st)
 	out.Attr.FromStat(&st)
-	node := newNode(n.RootData, n.EmbeddedInode(), name, &st)
+	node := n.sfs.newNode(n.RootData, n.EmbeddedInode(), name, &st)
 	ch := n.NewInode(ctx, node, idFromStat(n.RootData, &st))
 	return ch, 0
 }
 
 func (n *LoopbackNode) Readlink(ctx context.Context) ([]byte, syscall.Errno) {
 	p := n.path()
-	logger.LogEvent("Readlink", p)
+	n.sfs.Record(&event.Event{Type: event.Readlink, Path: p})
 
 	for l := 256; ; l *= 2 {
 		buf := make([]byte, l)
@@ -89,7 +91,7 @@ func (n *LoopbackNode) Flush(ctx context.Context, fh fs.FileHandle) syscall.Errn
 	if !ok {
 		fmt.Printf("Warning: cannot serialize %s back to wrapped file, this should not happen\n", p)
 	}
-	logger.LogEvent("Close", fmt.Sprintf("%s\t%d", p, wf.Fid))
+	n.sfs.Record(&event.Event{Type: event.Close, Path: p, Fd: wf.Fid})
 	return 0
 }
 
@@ -129,7 +131,7 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, u
 		utils.CopyFile(originalPath, cachePath)
 		cache[originalPath] = cachePath
 	}
-	logger.LogEvent("Open", cachePath)
+	n.sfs.Record(&event.Event{Type: event.Open, Path: cachePath})
 
 	// This next section emulates:
 	// 	fh, flags, errno := n.LoopbackNode.Open(ctx, flags)
@@ -150,24 +152,33 @@ func (n *LoopbackNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, u
 }
 
 func (n *LoopbackNode) Create(ctx context.Context, name string, flags uint32, mode uint32, out *fuse.EntryOut) (*fs.Inode, fs.FileHandle, uint32, syscall.Errno) {
-	logger.LogEvent("Create", name)
+	n.sfs.Record(&event.Event{Type: event.Create, Path: name})
 	inode, fh, flags, errno := n.LoopbackNode.Create(ctx, name, flags, mode, out)
 	return inode, fh, flags, errno
 }
 
-func newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
+func (sfs *SpindleFS) newNode(rootData *fs.LoopbackRoot, parent *fs.Inode, name string, st *syscall.Stat_t) fs.InodeEmbedder {
 	n := &LoopbackNode{
 		LoopbackNode: fs.LoopbackNode{
 			RootData: rootData,
 		},
+		sfs: sfs,
 	}
 	return n
 }
 
+// Record logs an event and sends it to subscribers
+func (sfs *SpindleFS) Record(e *event.Event) {
+	e.Timestamp = time.Now().UnixNano()
+	if sfs.Logger != nil {
+		sfs.Logger.LogEvent(e)
+	}
+	sfs.Stream.Publish(e)
+}
+
 // InitLoopbackRoot creates a fuse.Server
 func (sfs *SpindleFS) InitLoopbackRoot(
 	rootPath string,
-	updates chan Update,
 	readOnly bool,
 ) error {
 
@@ -209,13 +220,13 @@ func (sfs *SpindleFS) InitLoopbackRoot(
 	}
 
 	rootData := &fs.LoopbackRoot{
-		NewNode: newNode,
+		NewNode: sfs.newNode,
 		Path:    rootPath,
 		Dev:     uint64(st.Dev),
 	}
 
 	// This is going to block
-	server, err := fs.Mount(sfs.RootFS(), newNode(rootData, nil, "", &st), options)
+	server, err := fs.Mount(sfs.RootFS(), sfs.newNode(rootData, nil, "", &st), options)
 	sfs.Server = server
 	return err
 }
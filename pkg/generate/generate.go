@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sort"
 
 	"github.com/u-root/u-root/pkg/ldd"
 )
@@ -20,7 +21,7 @@ func FindSharedLibs(path string) ([]string, error) {
 	// Do this and get the soname
 	sonames := map[string]bool{}
 	for _, path := range libs {
-		soname, err := readSoname(path)
+		soname, err := ReadSoname(path)
 		if err != nil {
 			fmt.Printf("Warning, cannot read soname of %s\n", path)
 			continue
@@ -35,15 +36,17 @@ func FindSharedLibs(path string) ([]string, error) {
 	for soname := range sonames {
 		sonameList = append(sonameList, soname)
 	}
+	sort.Strings(sonameList)
 	return sonameList, err
 }
 
 // ReadSoname from an ELF file
-func readSoname(filename string) (string, error) {
+func ReadSoname(filename string) (string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 	elfFile, err := elf.NewFile(file)
 	if err != nil {
 		return "", fmt.Errorf("could not parse ELF file %s: %v", filename, err)
@@ -0,0 +1,119 @@
+package generate
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+)
+
+// Machine names that differ from the lowercase ELF machine name
+var machineNames = map[elf.Machine]string{
+	elf.EM_X86_64:  "x86_64",
+	elf.EM_386:     "i386",
+	elf.EM_AARCH64: "aarch64",
+	elf.EM_ARM:     "arm",
+	elf.EM_PPC64:   "ppc64",
+	elf.EM_PPC:     "ppc",
+	elf.EM_S390:    "s390x",
+	elf.EM_RISCV:   "riscv64",
+}
+
+// ReadBinaryProperties reads properties of the ELF header, the interpreter
+// (PT_INTERP) and the minimum kernel version from the .note.ABI-tag
+func ReadBinaryProperties(filename string) (*compat.BinaryProperties, error) {
+	elfFile, err := elf.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ELF file %s: %v", filename, err)
+	}
+	defer elfFile.Close()
+
+	props := compat.BinaryProperties{
+		Machine:    machineName(elfFile),
+		Class:      className(elfFile),
+		Endianness: "little",
+		OSABI:      strings.ToLower(strings.TrimPrefix(elfFile.OSABI.String(), "ELFOSABI_")),
+	}
+	if elfFile.Data == elf.ELFDATA2MSB {
+		props.Endianness = "big"
+	}
+
+	for _, prog := range elfFile.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		interp, err := io.ReadAll(prog.Open())
+		if err != nil {
+			return &props, fmt.Errorf("cannot read interpreter of %s: %v", filename, err)
+		}
+		props.Interpreter = string(bytes.TrimRight(interp, "\x00"))
+	}
+
+	section := elfFile.Section(".note.ABI-tag")
+	if section != nil {
+		data, err := section.Data()
+		if err != nil {
+			return &props, fmt.Errorf("cannot read .note.ABI-tag of %s: %v", filename, err)
+		}
+		props.MinKernel = readABITag(data, elfFile.ByteOrder)
+	}
+	return &props, nil
+}
+
+// ReadMachine reads the machine and class of an ELF file, named as they
+// are in the properties of a binary
+func ReadMachine(filename string) (string, string, error) {
+	elfFile, err := elf.Open(filename)
+	if err != nil {
+		return "", "", fmt.Errorf("could not parse ELF file %s: %v", filename, err)
+	}
+	defer elfFile.Close()
+	return machineName(elfFile), className(elfFile), nil
+}
+
+// className returns the class of an ELF file (ELF32 or ELF64)
+func className(elfFile *elf.File) string {
+	return "ELF" + strings.TrimPrefix(elfFile.Class.String(), "ELFCLASS")
+}
+
+// machineName returns the name of the machine, accounting for endianness
+func machineName(elfFile *elf.File) string {
+	name, ok := machineNames[elfFile.Machine]
+	if !ok {
+		name = strings.ToLower(strings.TrimPrefix(elfFile.Machine.String(), "EM_"))
+	}
+	if elfFile.Machine == elf.EM_RISCV && elfFile.Class == elf.ELFCLASS32 {
+		name = "riscv32"
+	}
+	if elfFile.Machine == elf.EM_PPC64 && elfFile.Data == elf.ELFDATA2LSB {
+		name = "ppc64le"
+	}
+	return name
+}
+
+// readABITag parses the GNU ABI note, which has the OS (0 for Linux)
+// and the major, minor, and patch of the minimum kernel version
+func readABITag(data []byte, order binary.ByteOrder) string {
+	if len(data) < 12 {
+		return ""
+	}
+	namesz := order.Uint32(data[0:4])
+	descsz := order.Uint32(data[4:8])
+	noteType := order.Uint32(data[8:12])
+
+	// The name is padded to 4 bytes
+	start := 12 + (int(namesz)+3)&^3
+	if noteType != 1 || descsz < 16 || len(data) < start+16 {
+		return ""
+	}
+	name := string(bytes.TrimRight(data[12:12+namesz], "\x00"))
+	desc := data[start:]
+	if name != "GNU" || order.Uint32(desc[0:4]) != 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d", order.Uint32(desc[4:8]), order.Uint32(desc[8:12]), order.Uint32(desc[12:16]))
+}
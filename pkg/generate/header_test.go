@@ -0,0 +1,79 @@
+package generate
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"testing"
+)
+
+// abiNote builds a note with a name, type, and words of its description
+func abiNote(order binary.AppendByteOrder, name string, noteType uint32, desc ...uint32) []byte {
+	data := order.AppendUint32(nil, uint32(len(name)+1))
+	data = order.AppendUint32(data, uint32(len(desc)*4))
+	data = order.AppendUint32(data, noteType)
+	data = append(append(data, name...), 0)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	for _, word := range desc {
+		data = order.AppendUint32(data, word)
+	}
+	return data
+}
+
+func TestReadABITag(t *testing.T) {
+	little, big := binary.LittleEndian, binary.BigEndian
+	oversized := abiNote(little, "GNU", 1, 0, 3, 2, 0)
+	little.PutUint32(oversized[0:4], 0xffffffff)
+
+	tests := []struct {
+		name  string
+		data  []byte
+		order binary.ByteOrder
+		want  string
+	}{
+		{"linux", abiNote(little, "GNU", 1, 0, 3, 2, 0), little, "3.2.0"},
+		{"big endian", abiNote(big, "GNU", 1, 0, 4, 18, 1), big, "4.18.1"},
+		{"other os", abiNote(little, "GNU", 1, 1, 3, 2, 0), little, ""},
+		{"other name", abiNote(little, "FreeBSD", 1, 0, 3, 2, 0), little, ""},
+		{"other type", abiNote(little, "GNU", 3, 0, 3, 2, 0), little, ""},
+		{"short description", abiNote(little, "GNU", 1, 0, 3, 2), little, ""},
+		{"truncated", abiNote(little, "GNU", 1, 0, 3, 2, 0)[:20], little, ""},
+		{"oversized name", oversized, little, ""},
+		{"empty", nil, little, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := readABITag(test.data, test.order); got != test.want {
+				t.Errorf("readABITag() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadBinaryProperties(t *testing.T) {
+	note := abiNote(binary.LittleEndian, "GNU", 1, 0, 3, 2, 0)
+
+	tests := []struct {
+		name      string
+		sections  []elfSection
+		minKernel string
+	}{
+		{"abi note", []elfSection{{name: ".note.ABI-tag", kind: elf.SHT_NOTE, data: note}}, "3.2.0"},
+		{"no abi note", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			props, err := ReadBinaryProperties(writeELF(t, test.sections...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if props.Machine != "x86_64" || props.Class != "ELF64" || props.Endianness != "little" {
+				t.Errorf("ReadBinaryProperties() = %s %s %s, want x86_64 ELF64 little", props.Machine, props.Class, props.Endianness)
+			}
+			if props.MinKernel != test.minKernel {
+				t.Errorf("ReadBinaryProperties() kernel = %q, want %q", props.MinKernel, test.minKernel)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package generate
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+)
+
+// Directories in a rootfs that never have binaries we care about
+var skipDirectories = map[string]bool{
+	"/proc": true,
+	"/sys":  true,
+	"/dev":  true,
+}
+
+// A Rootfs is a summary of the ELF files in a container filesystem
+type Rootfs struct {
+	Root string
+
+	// Executables are paths (relative to the root) of ELF executables
+	Executables []string
+
+	// Provided are shared libraries (soname) bundled in the rootfs
+	Provided map[string]string
+
+	// Needed are sonames (DT_NEEDED) of any ELF in the rootfs
+	Needed map[string]bool
+
+	// Needs are symbol versions needed from each library (soname)
+	Needs map[string][]string
+
+	// Properties of the executables (see commonProperties)
+	Properties *compat.BinaryProperties
+}
+
+// ScanRootfs walks an unpacked container filesystem and reads every ELF
+// executable and shared object to find the libraries they need, and which
+// of those are bundled in the rootfs
+func ScanRootfs(root string) (*Rootfs, error) {
+	rootfs := Rootfs{
+		Root:        root,
+		Executables: []string{},
+		Provided:    map[string]string{},
+		Needed:      map[string]bool{},
+		Needs:       map[string][]string{},
+	}
+	needs := map[string]map[string]bool{}
+	properties := []*compat.BinaryProperties{}
+
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		relpath := "/" + rel(root, path)
+		if entry.IsDir() {
+			if skipDirectories[relpath] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || !isELF(path) {
+			return nil
+		}
+		elfFile, err := elf.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer elfFile.Close()
+
+		libs, err := elfFile.ImportedLibraries()
+		if err != nil {
+			return nil
+		}
+		for _, lib := range libs {
+			rootfs.Needed[lib] = true
+		}
+		// Libraries without a soname (e.g., plugins) are found by file name
+		executable := isExecutable(elfFile)
+		soname, err := ReadSoname(path)
+		if err == nil && soname == "" && !executable {
+			soname = filepath.Base(path)
+		}
+		if soname != "" {
+			if _, ok := rootfs.Provided[soname]; !ok {
+				rootfs.Provided[soname] = relpath
+			}
+		}
+
+		// Executables have an interpreter (or are not dynamic at all)
+		if executable {
+			rootfs.Executables = append(rootfs.Executables, relpath)
+			props, err := ReadBinaryProperties(path)
+			if err == nil {
+				properties = append(properties, props)
+			}
+		}
+
+		versions, err := ReadVersionNeeds(path)
+		if err != nil {
+			return nil
+		}
+		for lib, libVersions := range versions {
+			if _, ok := needs[lib]; !ok {
+				needs[lib] = map[string]bool{}
+			}
+			for _, version := range libVersions {
+				needs[lib][version] = true
+			}
+		}
+		return nil
+	})
+
+	for lib, versions := range needs {
+		for version := range versions {
+			rootfs.Needs[lib] = append(rootfs.Needs[lib], version)
+		}
+		sort.Strings(rootfs.Needs[lib])
+	}
+	sort.Strings(rootfs.Executables)
+	rootfs.Properties = commonProperties(properties)
+	return &rootfs, err
+}
+
+// Bundled returns the path of a soname if it is provided inside the rootfs
+func (r *Rootfs) Bundled(soname string) (string, bool) {
+	path, ok := r.Provided[soname]
+	return path, ok
+}
+
+// Artifact generates an artifact to describe the container filesystem,
+// with libraries bundled in the image or expected from the host
+func (r *Rootfs) Artifact(name string) *compat.CompatibiitySpec {
+	artifact := compat.NewCompatibilitySpec()
+	artifact.Executable.Name = name
+	artifact.Image = &compat.Image{Reference: name, Executables: r.Executables}
+
+	// The interpreter is only needed from the host if it is not bundled
+	props := r.Properties
+	if props != nil {
+		if _, err := os.Lstat(filepath.Join(r.Root, props.Interpreter)); err != nil {
+			artifact.Executable.Interpreter = props.Interpreter
+		}
+		artifact.Executable.OSABI = props.OSABI
+		artifact.Executable.MinKernel = props.MinKernel
+		artifact.Hardware = compat.Hardware{
+			Machine:    props.Machine,
+			Class:      props.Class,
+			Endianness: props.Endianness,
+		}
+	}
+
+	sonames := []string{}
+	for soname := range r.Needed {
+		sonames = append(sonames, soname)
+	}
+	sort.Strings(sonames)
+	for _, soname := range sonames {
+		if path, ok := r.Bundled(soname); ok {
+			artifact.AddBundledLibrary(soname, path)
+		} else {
+			artifact.AddLibrary(soname)
+		}
+	}
+
+	// Symbol versions are only checked for libraries from the host
+	libraries := []string{}
+	for lib := range r.Needs {
+		if _, ok := r.Bundled(lib); !ok && r.Needed[lib] {
+			libraries = append(libraries, lib)
+		}
+	}
+	sort.Strings(libraries)
+	for _, lib := range libraries {
+		for _, version := range r.Needs[lib] {
+			artifact.AddSymbol(lib, version)
+		}
+	}
+	return artifact
+}
+
+// commonProperties returns the properties that most executables of a
+// rootfs have (e.g., a few tools built for another machine do not change
+// its platform), with the highest minimum kernel of any of them, since the
+// host must run that for them all to run. It is nil without executables.
+func commonProperties(all []*compat.BinaryProperties) *compat.BinaryProperties {
+	if len(all) == 0 {
+		return nil
+	}
+
+	// The machine, class and endianness are taken together so they agree
+	common := *mostCommon(all, func(props *compat.BinaryProperties) string {
+		return props.Machine + "/" + props.Class + "/" + props.Endianness
+	})
+	common.OSABI = mostCommon(all, func(props *compat.BinaryProperties) string {
+		return props.OSABI
+	}).OSABI
+	common.Interpreter = mostCommon(all, func(props *compat.BinaryProperties) string {
+		return props.Interpreter
+	}).Interpreter
+	for _, props := range all {
+		if newerKernel(props.MinKernel, common.MinKernel) {
+			common.MinKernel = props.MinKernel
+		}
+	}
+	return &common
+}
+
+// mostCommon returns the first properties with the most common key
+func mostCommon(all []*compat.BinaryProperties, key func(*compat.BinaryProperties) string) *compat.BinaryProperties {
+	counts := map[string]int{}
+	for _, props := range all {
+		counts[key(props)]++
+	}
+	best := all[0]
+	for _, props := range all {
+		if counts[key(props)] > counts[key(best)] {
+			best = props
+		}
+	}
+	return best
+}
+
+// newerKernel determines if a kernel version (e.g., 3.2.0) is newer than
+// another, which can be empty
+func newerKernel(version, other string) bool {
+	if version == "" || other == "" {
+		return other == "" && version != ""
+	}
+	parts, others := strings.Split(version, "."), strings.Split(other, ".")
+	for i := range max(len(parts), len(others)) {
+		number, otherNumber := 0, 0
+		if i < len(parts) {
+			number, _ = strconv.Atoi(parts[i])
+		}
+		if i < len(others) {
+			otherNumber, _ = strconv.Atoi(others[i])
+		}
+		if number != otherNumber {
+			return number > otherNumber
+		}
+	}
+	return false
+}
+
+// isExecutable determines if an ELF file is an executable (and not a library)
+func isExecutable(elfFile *elf.File) bool {
+	if elfFile.Type == elf.ET_EXEC {
+		return true
+	}
+	if elfFile.Type != elf.ET_DYN {
+		return false
+	}
+	for _, prog := range elfFile.Progs {
+		if prog.Type == elf.PT_INTERP {
+			return true
+		}
+	}
+	return false
+}
+
+// isELF checks the magic bytes of a file
+func isELF(path string) bool {
+	fd, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer fd.Close()
+	magic := make([]byte, len(elf.ELFMAG))
+	_, err = io.ReadFull(fd, magic)
+	return err == nil && bytes.Equal(magic, []byte(elf.ELFMAG))
+}
+
+// rel returns a path relative to the root (empty for the root, or if it
+// is not under it)
+func rel(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return rel
+}
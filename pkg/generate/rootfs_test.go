@@ -0,0 +1,250 @@
+package generate
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+)
+
+const ldLinux = "/lib64/ld-linux-x86-64.so.2"
+
+// rootfsELF is an ELF file in a test rootfs
+type rootfsELF struct {
+	path    string
+	kind    elf.Type
+	machine elf.Machine
+	interp  string
+	soname  string
+	needs   []versionNeed
+
+	// Minimum kernel in the .note.ABI-tag (none if empty)
+	kernel []uint32
+}
+
+// dynamic builds a .dynamic section with a soname (if set) and the
+// libraries needed
+func dynamic(strtab *stringTable, soname string, needs []versionNeed) elfSection {
+	order := binary.LittleEndian
+	data := []byte{}
+	if soname != "" {
+		data = order.AppendUint64(data, uint64(elf.DT_SONAME))
+		data = order.AppendUint64(data, uint64(strtab.add(soname)))
+	}
+	for _, need := range needs {
+		data = order.AppendUint64(data, uint64(elf.DT_NEEDED))
+		data = order.AppendUint64(data, uint64(strtab.add(need.library)))
+	}
+	data = append(data, make([]byte, 16)...)
+	return elfSection{name: ".dynamic", kind: elf.SHT_DYNAMIC, data: data}
+}
+
+// writeRootfs writes ELF files (and a file that is not one) to a rootfs
+func writeRootfs(t *testing.T, files ...rootfsELF) string {
+	root := t.TempDir()
+	for _, file := range files {
+		strtab := &stringTable{}
+		sections := []elfSection{dynamic(strtab, file.soname, file.needs)}
+		versioned := []versionNeed{}
+		for _, need := range file.needs {
+			if len(need.versions) > 0 {
+				versioned = append(versioned, need)
+			}
+		}
+		if len(versioned) > 0 {
+			sections = append(sections, verneed(strtab, versioned...))
+		}
+		if len(file.kernel) > 0 {
+			note := abiNote(binary.LittleEndian, "GNU", 1, append([]uint32{0}, file.kernel...)...)
+			sections = append(sections, elfSection{name: ".note.ABI-tag", kind: elf.SHT_NOTE, data: note})
+		}
+		data := buildELF(file.kind, file.machine, file.interp, append([]elfSection{strtab.section()}, sections...)...)
+
+		path := filepath.Join(root, file.path)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, data, 0755)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.MkdirAll(filepath.Join(root, "etc"), 0755)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(root, "etc", "hostname"), []byte("lammps\n"), 0644)
+	}
+	if err == nil {
+		err = os.Symlink("libfoo.so.1", filepath.Join(root, "usr", "lib", "libfoo.so"))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// lammpsRootfs has two x86-64 programs, one for arm that most do not
+// match, a library with a soname, and a plugin without one
+var lammpsRootfs = []rootfsELF{
+	{
+		path:    "/usr/bin/lmp",
+		kind:    elf.ET_DYN,
+		machine: elf.EM_X86_64,
+		interp:  ldLinux,
+		needs: []versionNeed{
+			{"libc.so.6", []string{"GLIBC_2.34"}},
+			{"libfoo.so.1", []string{"FOO_1.0"}},
+		},
+		kernel: []uint32{3, 2, 0},
+	},
+	{
+		path:    "/usr/bin/binary2lammps",
+		kind:    elf.ET_DYN,
+		machine: elf.EM_X86_64,
+		interp:  ldLinux,
+		needs:   []versionNeed{{"libc.so.6", []string{"GLIBC_2.17"}}},
+		kernel:  []uint32{4, 4, 0},
+	},
+	{path: "/opt/arm/helper", kind: elf.ET_EXEC, machine: elf.EM_AARCH64},
+	{
+		path:    "/usr/lib/libfoo.so.1",
+		kind:    elf.ET_DYN,
+		machine: elf.EM_X86_64,
+		soname:  "libfoo.so.1",
+		needs:   []versionNeed{{"libc.so.6", nil}},
+	},
+	{path: "/usr/lib/lammps/plugin.so", kind: elf.ET_DYN, machine: elf.EM_X86_64},
+
+	// Not a file of the rootfs
+	{path: "/proc/1/exe", kind: elf.ET_EXEC, machine: elf.EM_X86_64},
+}
+
+func TestScanRootfs(t *testing.T) {
+	rootfs, err := ScanRootfs(writeRootfs(t, lammpsRootfs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	executables := []string{"/opt/arm/helper", "/usr/bin/binary2lammps", "/usr/bin/lmp"}
+	if !reflect.DeepEqual(rootfs.Executables, executables) {
+		t.Errorf("Executables = %v, want %v", rootfs.Executables, executables)
+	}
+	provided := map[string]string{"libfoo.so.1": "/usr/lib/libfoo.so.1", "plugin.so": "/usr/lib/lammps/plugin.so"}
+	if !reflect.DeepEqual(rootfs.Provided, provided) {
+		t.Errorf("Provided = %v, want %v", rootfs.Provided, provided)
+	}
+	needed := map[string]bool{"libc.so.6": true, "libfoo.so.1": true}
+	if !reflect.DeepEqual(rootfs.Needed, needed) {
+		t.Errorf("Needed = %v, want %v", rootfs.Needed, needed)
+	}
+	needs := map[string][]string{"libc.so.6": {"GLIBC_2.17", "GLIBC_2.34"}, "libfoo.so.1": {"FOO_1.0"}}
+	if !reflect.DeepEqual(rootfs.Needs, needs) {
+		t.Errorf("Needs = %v, want %v", rootfs.Needs, needs)
+	}
+
+	// The machine of most executables, and the newest kernel any needs
+	props := compat.BinaryProperties{
+		Machine:     "x86_64",
+		Class:       "ELF64",
+		Endianness:  "little",
+		OSABI:       "none",
+		Interpreter: ldLinux,
+		MinKernel:   "4.4.0",
+	}
+	if rootfs.Properties == nil || *rootfs.Properties != props {
+		t.Errorf("Properties = %+v, want %+v", rootfs.Properties, props)
+	}
+}
+
+func TestArtifact(t *testing.T) {
+	lammpsLibraries := []compat.Library{
+		{Soname: "libc.so.6"},
+		{Soname: "libfoo.so.1", Bundled: true, Path: "/usr/lib/libfoo.so.1"},
+	}
+
+	tests := []struct {
+		name  string
+		files []rootfsELF
+
+		// The interpreter expected from the host
+		interp    string
+		machine   string
+		libraries []compat.Library
+	}{
+		{name: "host interpreter", files: lammpsRootfs, interp: ldLinux, machine: "x86_64", libraries: lammpsLibraries},
+		{
+			name:      "bundled interpreter",
+			files:     append([]rootfsELF{{path: ldLinux, kind: elf.ET_DYN, machine: elf.EM_X86_64}}, lammpsRootfs...),
+			machine:   "x86_64",
+			libraries: lammpsLibraries,
+		},
+		{name: "no executables", files: lammpsRootfs[3:5], libraries: []compat.Library{{Soname: "libc.so.6"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rootfs, err := ScanRootfs(writeRootfs(t, test.files...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			artifact := rootfs.Artifact("ghcr.io/lammps/lammps:latest")
+			if artifact.Executable.Name != "ghcr.io/lammps/lammps:latest" || artifact.Image == nil {
+				t.Fatalf("Artifact() is not for the image: %+v", artifact)
+			}
+			if artifact.Executable.Interpreter != test.interp {
+				t.Errorf("Artifact() interpreter = %q, want %q", artifact.Executable.Interpreter, test.interp)
+			}
+			if artifact.Hardware.Machine != test.machine {
+				t.Errorf("Artifact() machine = %q, want %q", artifact.Hardware.Machine, test.machine)
+			}
+
+			if !reflect.DeepEqual(artifact.Libraries, test.libraries) {
+				t.Errorf("Artifact() libraries = %+v, want %+v", artifact.Libraries, test.libraries)
+			}
+
+			// Symbol versions are only for libraries from the host
+			symbols := []compat.Symbol{}
+			for _, version := range rootfs.Needs["libc.so.6"] {
+				symbols = append(symbols, compat.Symbol{Library: "libc.so.6", Version: version})
+			}
+			if !reflect.DeepEqual(artifact.Symbols, symbols) {
+				t.Errorf("Artifact() symbols = %+v, want %+v", artifact.Symbols, symbols)
+			}
+		})
+	}
+}
+
+func TestCommonProperties(t *testing.T) {
+	x86 := &compat.BinaryProperties{Machine: "x86_64", Class: "ELF64", Endianness: "little", OSABI: "none", MinKernel: "3.2.0"}
+	newer := &compat.BinaryProperties{Machine: "x86_64", Class: "ELF64", Endianness: "little", OSABI: "none", MinKernel: "3.10.0"}
+	i386 := &compat.BinaryProperties{Machine: "i386", Class: "ELF32", Endianness: "little", OSABI: "linux"}
+
+	tests := []struct {
+		name string
+		all  []*compat.BinaryProperties
+		want *compat.BinaryProperties
+	}{
+		{name: "none"},
+		{name: "one", all: []*compat.BinaryProperties{i386}, want: i386},
+		{
+			name: "most common machine",
+			all:  []*compat.BinaryProperties{i386, x86, newer},
+			want: newer,
+		},
+		{
+			name: "tied",
+			all:  []*compat.BinaryProperties{i386, x86},
+			want: &compat.BinaryProperties{Machine: "i386", Class: "ELF32", Endianness: "little", OSABI: "linux", MinKernel: "3.2.0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := commonProperties(test.all)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("commonProperties() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
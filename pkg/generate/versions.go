@@ -0,0 +1,164 @@
+package generate
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/compspec/compat-lib/pkg/utils"
+	"github.com/u-root/u-root/pkg/ldd"
+)
+
+// ReadAllVersionNeeds reads the symbol versions needed by an executable
+// and by each library it loads (from ldd.FList), since a library can
+// require a newer version of another (e.g., libstdc++ from libc).
+func ReadAllVersionNeeds(path string) (map[string][]string, error) {
+	libs, err := ldd.FList(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list libraries of %s: %v", path, err)
+	}
+	needs := map[string]map[string]bool{}
+	for _, filename := range append([]string{path}, libs...) {
+
+		// FList has both the symlinks and the files they point to
+		info, err := os.Lstat(filename)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		fileNeeds, err := ReadVersionNeeds(filename)
+		if err != nil {
+			if filename == path {
+				return nil, err
+			}
+			fmt.Printf("Warning, cannot read symbol versions needed by %s\n", filename)
+			continue
+		}
+		for library, versions := range fileNeeds {
+			if _, ok := needs[library]; !ok {
+				needs[library] = map[string]bool{}
+			}
+			for _, version := range versions {
+				needs[library][version] = true
+			}
+		}
+	}
+
+	merged := map[string][]string{}
+	for library, versions := range needs {
+		for version := range versions {
+			merged[library] = append(merged[library], version)
+		}
+		sort.Strings(merged[library])
+	}
+	return merged, nil
+}
+
+// ReadVersionNeeds reads the symbol versions an ELF file requires from
+// each library (soname), from the .gnu.version_r section.
+func ReadVersionNeeds(filename string) (map[string][]string, error) {
+	needs := map[string][]string{}
+	elfFile, err := elf.Open(filename)
+	if err != nil {
+		return needs, fmt.Errorf("could not parse ELF file %s: %v", filename, err)
+	}
+	defer elfFile.Close()
+
+	section, strtab, err := versionSection(elfFile, elf.SHT_GNU_VERNEED)
+	if section == nil || err != nil {
+		return needs, err
+	}
+	data, err := section.Data()
+	if err != nil {
+		return needs, err
+	}
+	order := elfFile.ByteOrder
+
+	// Each Elf_Verneed entry (16 bytes) has a list of Elf_Vernaux (16 bytes)
+	offset := 0
+	for i := 0; i < int(section.Info); i++ {
+		if offset+16 > len(data) {
+			return needs, fmt.Errorf("%s has a truncated .gnu.version_r", filename)
+		}
+		entry := data[offset:]
+		count := int(order.Uint16(entry[2:4]))
+		library := utils.CString(strtab, order.Uint32(entry[4:8]))
+		aux := offset + int(order.Uint32(entry[8:12]))
+		for j := 0; j < count; j++ {
+			if aux+16 > len(data) {
+				return needs, fmt.Errorf("%s has a truncated .gnu.version_r", filename)
+			}
+			auxEntry := data[aux:]
+			needs[library] = append(needs[library], utils.CString(strtab, order.Uint32(auxEntry[8:12])))
+			aux += int(order.Uint32(auxEntry[12:16]))
+		}
+		next := order.Uint32(entry[12:16])
+		if next == 0 {
+			break
+		}
+		offset += int(next)
+	}
+	for library := range needs {
+		sort.Strings(needs[library])
+	}
+	return needs, nil
+}
+
+// ReadVersionDefinitions reads the symbol versions defined by a library
+// from the .gnu.version_d section. The base definition (the soname) is skipped.
+func ReadVersionDefinitions(filename string) ([]string, error) {
+	versions := []string{}
+	elfFile, err := elf.Open(filename)
+	if err != nil {
+		return versions, fmt.Errorf("could not parse ELF file %s: %v", filename, err)
+	}
+	defer elfFile.Close()
+
+	section, strtab, err := versionSection(elfFile, elf.SHT_GNU_VERDEF)
+	if section == nil || err != nil {
+		return versions, err
+	}
+	data, err := section.Data()
+	if err != nil {
+		return versions, err
+	}
+	order := elfFile.ByteOrder
+
+	// Each Elf_Verdef entry (20 bytes) is followed by Elf_Verdaux (8 bytes)
+	// and the first auxiliary entry has the version name.
+	offset := 0
+	for i := 0; i < int(section.Info); i++ {
+		if offset+20 > len(data) {
+			return versions, fmt.Errorf("%s has a truncated .gnu.version_d", filename)
+		}
+		entry := data[offset:]
+		flags := order.Uint16(entry[2:4])
+		aux := offset + int(order.Uint32(entry[12:16]))
+		if flags&verFlagBase == 0 && aux+8 <= len(data) {
+			versions = append(versions, utils.CString(strtab, order.Uint32(data[aux:aux+4])))
+		}
+		next := order.Uint32(entry[16:20])
+		if next == 0 {
+			break
+		}
+		offset += int(next)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// Version definition flag for the file itself
+const verFlagBase = 0x1
+
+// versionSection returns a version section by type and its linked string table
+func versionSection(elfFile *elf.File, sectionType elf.SectionType) (*elf.Section, []byte, error) {
+	section := elfFile.SectionByType(sectionType)
+	if section == nil {
+		return nil, nil, nil
+	}
+	if int(section.Link) >= len(elfFile.Sections) {
+		return nil, nil, fmt.Errorf("section %s has an invalid string table link", section.Name)
+	}
+	strtab, err := elfFile.Sections[section.Link].Data()
+	return section, strtab, err
+}
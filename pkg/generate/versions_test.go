@@ -0,0 +1,246 @@
+package generate
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// elfSection is a section of a test ELF file
+type elfSection struct {
+	name string
+	kind elf.SectionType
+	info uint32
+	data []byte
+}
+
+// writeELF writes a 64-bit little endian x86-64 library with sections
+// (and no segments)
+func writeELF(t *testing.T, sections ...elfSection) string {
+	path := filepath.Join(t.TempDir(), "lib.so")
+	err := os.WriteFile(path, buildELF(elf.ET_DYN, elf.EM_X86_64, "", sections...), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// buildELF builds a 64-bit little endian ELF file with sections, and an
+// interpreter segment if it has one. The first section is linked from the
+// others, for sections that have a string table.
+func buildELF(kind elf.Type, machine elf.Machine, interp string, sections ...elfSection) []byte {
+	order := binary.LittleEndian
+	shstrtab := []byte{0}
+	headers := []elf.Section64{{}}
+	data := make([]byte, 64)
+	progs := []elf.Prog64{}
+	if interp != "" {
+		data = append(data, make([]byte, 56)...)
+		progs = append(progs, elf.Prog64{
+			Type:   uint32(elf.PT_INTERP),
+			Flags:  uint32(elf.PF_R),
+			Off:    uint64(len(data)),
+			Filesz: uint64(len(interp) + 1),
+			Memsz:  uint64(len(interp) + 1),
+			Align:  1,
+		})
+		data = append(append(data, interp...), 0)
+	}
+	for _, section := range append(sections, elfSection{name: ".shstrtab", kind: elf.SHT_STRTAB}) {
+		if section.name == ".shstrtab" {
+			section.data = append(shstrtab, ".shstrtab\x00"...)
+		}
+		headers = append(headers, elf.Section64{
+			Name:      uint32(len(shstrtab)),
+			Type:      uint32(section.kind),
+			Off:       uint64(len(data)),
+			Size:      uint64(len(section.data)),
+			Link:      1,
+			Info:      section.info,
+			Addralign: 1,
+		})
+		shstrtab = append(append(shstrtab, section.name...), 0)
+		data = append(data, section.data...)
+	}
+
+	header := elf.Header64{
+		Type:      uint16(kind),
+		Machine:   uint16(machine),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(len(data)),
+		Ehsize:    64,
+		Shentsize: 64,
+		Shnum:     uint16(len(headers)),
+		Shstrndx:  uint16(len(headers) - 1),
+	}
+	if len(progs) > 0 {
+		header.Phoff = 64
+		header.Phentsize = 56
+		header.Phnum = uint16(len(progs))
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	buffer := bytes.NewBuffer(nil)
+	binary.Write(buffer, order, header)
+	binary.Write(buffer, order, progs)
+	buffer.Write(data[64+56*len(progs):])
+	binary.Write(buffer, order, headers)
+	return buffer.Bytes()
+}
+
+// stringTable is the .dynstr of a test ELF file
+type stringTable []byte
+
+// add adds a string and returns its offset
+func (s *stringTable) add(name string) uint32 {
+	if len(*s) == 0 {
+		*s = append(*s, 0)
+	}
+	offset := uint32(len(*s))
+	*s = append(append(*s, name...), 0)
+	return offset
+}
+
+// section returns the table as the first section of a test ELF file
+func (s *stringTable) section() elfSection {
+	return elfSection{name: ".dynstr", kind: elf.SHT_STRTAB, data: *s}
+}
+
+// versionNeed is a library and the versions needed from it
+type versionNeed struct {
+	library  string
+	versions []string
+}
+
+// verneed builds a .gnu.version_r section, each Elf_Verneed followed
+// by its Elf_Vernaux entries
+func verneed(strtab *stringTable, needs ...versionNeed) elfSection {
+	order := binary.LittleEndian
+	data := []byte{}
+	for i, need := range needs {
+		next := uint32(16 + 16*len(need.versions))
+		if i == len(needs)-1 {
+			next = 0
+		}
+		data = order.AppendUint16(data, 1)
+		data = order.AppendUint16(data, uint16(len(need.versions)))
+		data = order.AppendUint32(data, strtab.add(need.library))
+		data = order.AppendUint32(data, 16)
+		data = order.AppendUint32(data, next)
+		for j, version := range need.versions {
+			next := uint32(16)
+			if j == len(need.versions)-1 {
+				next = 0
+			}
+			data = order.AppendUint32(data, 0)
+			data = order.AppendUint16(data, 0)
+			data = order.AppendUint16(data, uint16(i+j+2))
+			data = order.AppendUint32(data, strtab.add(version))
+			data = order.AppendUint32(data, next)
+		}
+	}
+	return elfSection{name: ".gnu.version_r", kind: elf.SHT_GNU_VERNEED, info: uint32(len(needs)), data: data}
+}
+
+// verdef builds a .gnu.version_d section with the base definition (the
+// soname) and versions, each Elf_Verdef followed by one Elf_Verdaux
+func verdef(strtab *stringTable, soname string, versions ...string) elfSection {
+	order := binary.LittleEndian
+	data := []byte{}
+	names := append([]string{soname}, versions...)
+	for i, name := range names {
+		flags, next := uint16(0), uint32(28)
+		if i == 0 {
+			flags = verFlagBase
+		}
+		if i == len(names)-1 {
+			next = 0
+		}
+		data = order.AppendUint16(data, 1)
+		data = order.AppendUint16(data, flags)
+		data = order.AppendUint16(data, uint16(i+1))
+		data = order.AppendUint16(data, 1)
+		data = order.AppendUint32(data, 0)
+		data = order.AppendUint32(data, 20)
+		data = order.AppendUint32(data, next)
+		data = order.AppendUint32(data, strtab.add(name))
+		data = order.AppendUint32(data, 0)
+	}
+	return elfSection{name: ".gnu.version_d", kind: elf.SHT_GNU_VERDEF, info: uint32(len(names)), data: data}
+}
+
+// truncate removes bytes from the end of a section
+func truncate(section elfSection, size int) elfSection {
+	section.data = section.data[:len(section.data)-size]
+	return section
+}
+
+func TestReadVersionNeeds(t *testing.T) {
+	strtab := &stringTable{}
+	section := verneed(strtab,
+		versionNeed{"libc.so.6", []string{"GLIBC_2.34", "GLIBC_2.2.5"}},
+		versionNeed{"libm.so.6", []string{"GLIBC_2.29"}},
+	)
+
+	needs, err := ReadVersionNeeds(writeELF(t, strtab.section(), section))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"libc.so.6": {"GLIBC_2.2.5", "GLIBC_2.34"}, "libm.so.6": {"GLIBC_2.29"}}
+	if !reflect.DeepEqual(needs, want) {
+		t.Errorf("ReadVersionNeeds() = %v, want %v", needs, want)
+	}
+
+	// A binary without the section needs no versions
+	needs, err = ReadVersionNeeds(writeELF(t, strtab.section()))
+	if err != nil || len(needs) != 0 {
+		t.Errorf("ReadVersionNeeds() = %v, %v without .gnu.version_r, want none", needs, err)
+	}
+
+	// An entry that runs past the end of the section is an error
+	_, err = ReadVersionNeeds(writeELF(t, strtab.section(), truncate(section, 8)))
+	if err == nil || !strings.Contains(err.Error(), "truncated .gnu.version_r") {
+		t.Errorf("ReadVersionNeeds() = %v for a truncated section", err)
+	}
+}
+
+func TestReadVersionDefinitions(t *testing.T) {
+	strtab := &stringTable{}
+	glibc := verdef(strtab, "libc.so.6", "GLIBC_2.34", "GLIBC_2.2.5", "GLIBC_PRIVATE")
+	base := verdef(strtab, "libfoo.so.1")
+
+	// The base definition is the soname of the library, not a version
+	tests := []struct {
+		name     string
+		sections []elfSection
+		versions []string
+	}{
+		{"versions", []elfSection{strtab.section(), glibc}, []string{"GLIBC_2.2.5", "GLIBC_2.34", "GLIBC_PRIVATE"}},
+		{"base only", []elfSection{strtab.section(), base}, []string{}},
+		{"no versions", []elfSection{strtab.section()}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			versions, err := ReadVersionDefinitions(writeELF(t, test.sections...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(versions, test.versions) {
+				t.Errorf("ReadVersionDefinitions() = %v, want %v", versions, test.versions)
+			}
+		})
+	}
+
+	_, err := ReadVersionDefinitions(writeELF(t, strtab.section(), truncate(glibc, 20)))
+	if err == nil || !strings.Contains(err.Error(), "truncated .gnu.version_d") {
+		t.Errorf("ReadVersionDefinitions() = %v for a truncated section", err)
+	}
+}
@@ -0,0 +1,125 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Host describes the ISA and kernel of the node
+type Host struct {
+	Machine    string
+	Class      string
+	Endianness string
+	Kernel     string
+}
+
+// Machines that can also run binaries for another machine (and class)
+var compatibleMachines = map[string]map[string]string{
+	"x86_64": {"i386": "ELF32"},
+}
+
+// Machines with a 64-bit ELF class. Others (e.g., i386 and arm) are 32-bit.
+var machines64 = map[string]bool{
+	"x86_64":      true,
+	"aarch64":     true,
+	"ppc64":       true,
+	"ppc64le":     true,
+	"s390x":       true,
+	"riscv64":     true,
+	"mips64":      true,
+	"sparc64":     true,
+	"loongarch64": true,
+}
+
+// Machines reported by uname for variants of one ELF machine (e.g., armv7l
+// and i686), which are named as they are from the ELF header
+var machineVariants = map[string]*regexp.Regexp{
+	"arm":  regexp.MustCompile(`^arm(v[0-9].*)?$`),
+	"i386": regexp.MustCompile(`^i[3-6]86$`),
+}
+
+// NewHost discovers properties of the host with uname
+func NewHost() (*Host, error) {
+	var uname syscall.Utsname
+	err := syscall.Uname(&uname)
+	if err != nil {
+		return nil, err
+	}
+	machine := normalizeMachine(utsString(uname.Machine[:]))
+	host := Host{
+		Machine:    machine,
+		Kernel:     utsString(uname.Release[:]),
+		Class:      "ELF32",
+		Endianness: "little",
+	}
+	if machines64[machine] {
+		host.Class = "ELF64"
+	}
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		host.Endianness = "big"
+	}
+	return &host, nil
+}
+
+// normalizeMachine names a machine from uname as it is named from the ELF
+// header
+func normalizeMachine(machine string) string {
+	for name, variant := range machineVariants {
+		if variant.MatchString(machine) {
+			return name
+		}
+	}
+	return machine
+}
+
+// SupportsMachine determines if the host can run a machine and class
+func (h *Host) SupportsMachine(machine, class string) bool {
+	if machine == h.Machine || machine == "" {
+		return class == "" || class == h.Class
+	}
+	other, ok := compatibleMachines[h.Machine][machine]
+	return ok && (class == "" || class == other)
+}
+
+// SupportsKernel determines if the host kernel is at least a version
+func (h *Host) SupportsKernel(minimum string) bool {
+	have := kernelVersion(h.Kernel)
+	for i, need := range kernelVersion(minimum) {
+		if i >= len(have) || have[i] < need {
+			return false
+		}
+		if have[i] > need {
+			return true
+		}
+	}
+	return true
+}
+
+// kernelVersion parses the numeric prefix of a release, e.g., 6.8.0-45-generic
+func kernelVersion(release string) []int {
+	version := []int{}
+	release, _, _ = strings.Cut(release, "-")
+	for _, part := range strings.Split(release, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		version = append(version, number)
+	}
+	return version
+}
+
+// utsString converts a null terminated uname field to a string
+func utsString[T int8 | uint8](field []T) string {
+	out := make([]byte, 0, len(field))
+	for _, c := range field {
+		if c == 0 {
+			break
+		}
+		out = append(out, byte(c))
+	}
+	return string(out)
+}
@@ -0,0 +1,53 @@
+package inventory
+
+import "testing"
+
+func TestNormalizeMachine(t *testing.T) {
+	tests := []struct {
+		machine string
+		want    string
+	}{
+		{"x86_64", "x86_64"},
+		{"aarch64", "aarch64"},
+		{"armv7l", "arm"},
+		{"armv6l", "arm"},
+		{"armv5tel", "arm"},
+		{"i686", "i386"},
+		{"i386", "i386"},
+		{"ppc64le", "ppc64le"},
+	}
+	for _, test := range tests {
+		t.Run(test.machine, func(t *testing.T) {
+			if got := normalizeMachine(test.machine); got != test.want {
+				t.Errorf("normalizeMachine(%q) = %q, want %q", test.machine, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSupportsMachine(t *testing.T) {
+	x86 := &Host{Machine: "x86_64", Class: "ELF64"}
+	arm := &Host{Machine: "arm", Class: "ELF32"}
+
+	tests := []struct {
+		name    string
+		host    *Host
+		machine string
+		class   string
+		want    bool
+	}{
+		{"same machine", x86, "x86_64", "ELF64", true},
+		{"unknown machine", x86, "", "", true},
+		{"32-bit on 64-bit", x86, "i386", "ELF32", true},
+		{"other machine", x86, "aarch64", "ELF64", false},
+		{"other class", x86, "x86_64", "ELF32", false},
+		{"arm variant", arm, "arm", "ELF32", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.host.SupportsMachine(test.machine, test.class); got != test.want {
+				t.Errorf("SupportsMachine(%q, %q) = %v, want %v", test.machine, test.class, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package inventory
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/generate"
+)
+
+const (
+	// Configuration and cache written by ldconfig
+	LdConf  = "/etc/ld.so.conf"
+	LdCache = "/etc/ld.so.cache"
+)
+
+// Default directories searched by the dynamic linker
+var DefaultLibraryPaths = []string{
+	"/lib",
+	"/lib64",
+	"/usr/lib",
+	"/usr/lib64",
+	"/usr/local/lib",
+	"/usr/local/lib64",
+}
+
+// An Inventory is an index of sonames to paths provided by the host.
+// It is built once and can be refreshed when the host changes.
+type Inventory struct {
+	mutex     sync.RWMutex
+	libraries map[string][]Library
+
+	// Symbol versions defined by each library path, read on demand
+	versions map[string]map[string]bool
+
+	// Extra prefixes (e.g., spack or module trees) to search recursively
+	Prefixes []string
+
+	// Linker cache and configuration (LdCache and LdConf)
+	ldCache string
+	ldConf  string
+
+	// Time of the last build
+	Updated time.Time
+
+	// Properties of the host (ISA and kernel)
+	Host *Host
+}
+
+// A Library is a path that provides a soname, with the machine and class
+// it is built for (empty if they cannot be read)
+type Library struct {
+	Path    string
+	Machine string
+	Class   string
+}
+
+// NewInventory builds an inventory of libraries on the host
+func NewInventory(prefixes []string) (*Inventory, error) {
+	host, err := NewHost()
+	if err != nil {
+		return nil, err
+	}
+	inv := Inventory{Prefixes: prefixes, Host: host, ldCache: LdCache, ldConf: LdConf}
+	err = inv.Refresh()
+	return &inv, err
+}
+
+// Refresh rebuilds the inventory. Lookups continue to use the previous
+// index until the new one is complete. A host without a linker cache or
+// configuration is fine, and one that cannot be read is skipped with a
+// warning, since the directories the linker searches are scanned too.
+func (inv *Inventory) Refresh() error {
+	start := time.Now()
+	idx := newIndex()
+
+	// Directories from LD_LIBRARY_PATH are searched first by the linker
+	for _, dir := range strings.Split(os.Getenv("LD_LIBRARY_PATH"), ":") {
+		if dir != "" {
+			idx.scanDirectory(dir)
+		}
+	}
+
+	// The cache has soname to path mappings ready for us, and usually
+	// the machine of each library
+	entries, err := readLdCache(inv.ldCache)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("warning: cannot read %s: %s", inv.ldCache, err)
+	}
+	for _, entry := range entries {
+		machine, class, ok := entry.Machine()
+		if !ok {
+			idx.add(entry.Soname, readLibrary(entry.Path))
+			continue
+		}
+		idx.add(entry.Soname, Library{Path: entry.Path, Machine: machine, Class: class})
+	}
+
+	// The cache can be stale, so also scan configured and default paths
+	dirs, err := readLdConf(inv.ldConf)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("warning: cannot read %s: %s", inv.ldConf, err)
+	}
+	for _, dir := range append(dirs, DefaultLibraryPaths...) {
+		idx.scanDirectory(dir)
+	}
+	for _, prefix := range inv.Prefixes {
+		idx.scanPrefix(prefix)
+	}
+
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	inv.libraries = idx.paths
+	inv.versions = map[string]map[string]bool{}
+	inv.Updated = time.Now()
+	log.Printf("🔎 found %d libraries on the host in %s", len(inv.libraries), time.Since(start))
+	return nil
+}
+
+// Lookup returns the paths that provide a soname for a machine and class,
+// in the order the linker searches them. An empty machine or class (of the
+// binary, or a library that could not be read) matches any.
+func (inv *Inventory) Lookup(soname, machine, class string) []string {
+	inv.mutex.RLock()
+	defer inv.mutex.RUnlock()
+	paths := []string{}
+	for _, library := range inv.libraries[soname] {
+		if machine != "" && library.Machine != "" && machine != library.Machine {
+			continue
+		}
+		if class != "" && library.Class != "" && class != library.Class {
+			continue
+		}
+		paths = append(paths, library.Path)
+	}
+	return paths
+}
+
+// Defines determines if the library at a path defines a symbol version.
+// Definitions are read from the ELF file the first time they are needed.
+func (inv *Inventory) Defines(path, version string) bool {
+	inv.mutex.RLock()
+	defined, ok := inv.versions[path]
+	inv.mutex.RUnlock()
+	if ok {
+		return defined[version]
+	}
+
+	defined = map[string]bool{}
+	versions, err := generate.ReadVersionDefinitions(path)
+	if err != nil {
+		log.Printf("warning: cannot read version definitions for %s: %s", path, err)
+	}
+	for _, v := range versions {
+		defined[v] = true
+	}
+	inv.mutex.Lock()
+	defer inv.mutex.Unlock()
+	inv.versions[path] = defined
+	return defined[version]
+}
+
+// Count returns the number of sonames known to the inventory
+func (inv *Inventory) Count() int {
+	inv.mutex.RLock()
+	defer inv.mutex.RUnlock()
+	return len(inv.libraries)
+}
+
+// An index holds libraries for each soname in search order,
+// deduplicated by resolved path
+type index struct {
+	paths map[string][]Library
+	seen  map[string]bool
+}
+
+// newIndex returns an empty index
+func newIndex() *index {
+	return &index{paths: map[string][]Library{}, seen: map[string]bool{}}
+}
+
+// add a library for a soname, ignoring paths that resolve to one we have
+func (idx *index) add(soname string, library Library) {
+	if soname == "" {
+		return
+	}
+	resolved, err := filepath.EvalSymlinks(library.Path)
+	if err != nil {
+		return
+	}
+	key := soname + "\x00" + resolved
+	if idx.seen[key] {
+		return
+	}
+	idx.seen[key] = true
+	idx.paths[soname] = append(idx.paths[soname], library)
+}
+
+// readLibrary reads the machine and class of the library at a path
+func readLibrary(path string) Library {
+	machine, class, err := generate.ReadMachine(path)
+	if err != nil {
+		return Library{Path: path}
+	}
+	return Library{Path: path, Machine: machine, Class: class}
+}
+
+// addFile reads the soname of a file and adds it to the index
+// Files that are not ELF (e.g., linker scripts) are skipped.
+func (idx *index) addFile(path string) {
+	soname, err := generate.ReadSoname(path)
+	if err != nil {
+		return
+	}
+	if soname == "" {
+		soname = filepath.Base(path)
+	}
+
+	// The linker looks for the soname in the same directory
+	linked := filepath.Join(filepath.Dir(path), soname)
+	if _, err := os.Stat(linked); err == nil {
+		path = linked
+	}
+	idx.add(soname, readLibrary(path))
+}
+
+// scanDirectory adds shared libraries directly under a directory
+func (idx *index) scanDirectory(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if isLibraryName(entry.Name()) && !entry.IsDir() {
+			idx.addFile(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+// scanPrefix walks a prefix recursively for shared libraries
+func (idx *index) scanPrefix(prefix string) {
+	err := filepath.WalkDir(prefix, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !entry.IsDir() && isLibraryName(entry.Name()) {
+			idx.addFile(path)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("warning: cannot scan prefix %s: %s", prefix, err)
+	}
+}
+
+// isLibraryName determines if a filename looks like a shared library
+func isLibraryName(name string) bool {
+	return strings.HasSuffix(name, ".so") || strings.Contains(name, ".so.")
+}
@@ -0,0 +1,101 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	inv := Inventory{libraries: map[string][]Library{
+		"libc.so.6": {
+			{Path: "/lib/x86_64-linux-gnu/libc.so.6", Machine: "x86_64", Class: "ELF64"},
+			{Path: "/lib/i386-linux-gnu/libc.so.6", Machine: "i386", Class: "ELF32"},
+			{Path: "/opt/lib/libc.so.6"},
+		},
+	}}
+
+	tests := []struct {
+		name    string
+		soname  string
+		machine string
+		class   string
+		paths   []string
+	}{
+		{"64-bit binary", "libc.so.6", "x86_64", "ELF64", []string{"/lib/x86_64-linux-gnu/libc.so.6", "/opt/lib/libc.so.6"}},
+		{"32-bit binary", "libc.so.6", "i386", "ELF32", []string{"/lib/i386-linux-gnu/libc.so.6", "/opt/lib/libc.so.6"}},
+		{"x32 binary", "libc.so.6", "x86_64", "ELF32", []string{"/opt/lib/libc.so.6"}},
+		{"unknown binary", "libc.so.6", "", "", []string{"/lib/x86_64-linux-gnu/libc.so.6", "/lib/i386-linux-gnu/libc.so.6", "/opt/lib/libc.so.6"}},
+		{"missing soname", "libm.so.6", "x86_64", "ELF64", []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths := inv.Lookup(test.soname, test.machine, test.class)
+			if !reflect.DeepEqual(paths, test.paths) {
+				t.Errorf("Lookup() = %v, want %v", paths, test.paths)
+			}
+		})
+	}
+}
+
+// A linker cache or configuration that cannot be read is skipped, and the
+// libraries in other directories are still found
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	libs := filepath.Join(dir, "libs")
+	err := os.Mkdir(libs, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Any ELF file will do for a library without a soname
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	library := filepath.Join(libs, "libcompat-test.so.1")
+	data, err := os.ReadFile(exe)
+	if err == nil {
+		err = os.WriteFile(library, data, 0644)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := filepath.Join(dir, "ld.so.conf")
+	err = os.WriteFile(conf, []byte(libs+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garbage := filepath.Join(dir, "ld.so.cache")
+	err = os.WriteFile(garbage, []byte("not a cache"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LD_LIBRARY_PATH", "")
+
+	tests := []struct {
+		name string
+		inv  *Inventory
+	}{
+		{name: "cache is a directory", inv: &Inventory{ldCache: dir, ldConf: conf}},
+		{name: "cache is not a cache", inv: &Inventory{ldCache: garbage, ldConf: conf}},
+		{name: "no cache", inv: &Inventory{ldCache: filepath.Join(dir, "missing"), ldConf: conf}},
+		{
+			name: "configuration is a directory",
+			inv:  &Inventory{ldCache: garbage, ldConf: dir, Prefixes: []string{libs}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.inv.Refresh()
+			if err != nil {
+				t.Fatalf("Refresh() = %s", err)
+			}
+			paths := test.inv.Lookup("libcompat-test.so.1", "", "")
+			if !reflect.DeepEqual(paths, []string{library}) {
+				t.Errorf("Lookup() = %v, want %v", paths, []string{library})
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package inventory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/utils"
+)
+
+const (
+	// Magic strings for the old (libc5) and new (glibc) ld.so.cache formats
+	cacheMagicOld = "ld.so-1.7.0"
+	cacheMagicNew = "glibc-ld.so.cache1.1"
+
+	// Sizes of headers and entries for each format
+	cacheHeaderOld = 16
+	cacheEntryOld  = 12
+	cacheHeaderNew = 48
+	cacheEntryNew  = 24
+)
+
+// Bits of the flags of an ld.so.cache entry with the machine and ABI the
+// library needs (the low byte is the type, e.g., ELF for glibc)
+const cacheFlagRequired = 0xff00
+
+// Machines and classes of the required flags (from glibc's ldconfig.h)
+// that tell them apart. Others (e.g., 0 for the default ABI of a 32-bit
+// machine, or powerpc, which does not have the endianness) are read from
+// the library.
+var cacheMachines = map[uint32][2]string{
+	0x0100: {"sparcv9", "ELF64"},
+	0x0200: {"ia_64", "ELF64"},
+	0x0300: {"x86_64", "ELF64"},
+	0x0400: {"s390x", "ELF64"},
+	0x0800: {"x86_64", "ELF32"},
+	0x0900: {"arm", "ELF32"},
+	0x0a00: {"aarch64", "ELF64"},
+	0x0b00: {"arm", "ELF32"},
+}
+
+// A cacheEntry is a soname and path pair from ld.so.cache, with the
+// flags ldconfig found for the library
+type cacheEntry struct {
+	Soname string
+	Path   string
+	Flags  uint32
+}
+
+// Machine returns the machine and class of the library, if the flags
+// have them
+func (e cacheEntry) Machine() (string, string, bool) {
+	machine, ok := cacheMachines[e.Flags&cacheFlagRequired]
+	return machine[0], machine[1], ok
+}
+
+// readLdConf parses an ld.so.conf file for library directories.
+// Include directives are followed (with glob patterns) relative to the file.
+func readLdConf(path string) ([]string, error) {
+	return readLdConfSeen(path, map[string]bool{})
+}
+
+// readLdConfSeen does the work of readLdConf, guarding against include loops
+func readLdConfSeen(path string, seen map[string]bool) ([]string, error) {
+	dirs := []string{}
+	if seen[path] {
+		return dirs, nil
+	}
+	seen[path] = true
+
+	fd, err := os.Open(path)
+	if err != nil {
+		return dirs, err
+	}
+	defer fd.Close()
+
+	s := bufio.NewScanner(fd)
+	for s.Scan() {
+		line := s.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		// hwcap directives are not supported by modern glibc
+		if fields[0] == "hwcap" {
+			continue
+		}
+		if fields[0] != "include" {
+			dirs = append(dirs, fields...)
+			continue
+		}
+		for _, pattern := range fields[1:] {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return dirs, err
+			}
+			for _, match := range matches {
+				included, err := readLdConfSeen(match, seen)
+				if err != nil {
+					return dirs, err
+				}
+				dirs = append(dirs, included...)
+			}
+		}
+	}
+	return dirs, s.Err()
+}
+
+// readLdCache parses the binary ld.so.cache written by ldconfig.
+// Both the new glibc format and the old format (optionally followed
+// by the new one) are supported.
+func readLdCache(path string) ([]cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte(cacheMagicNew)) {
+		return parseCacheNew(data)
+	}
+	if !bytes.HasPrefix(data, []byte(cacheMagicOld)) || len(data) < cacheHeaderOld {
+		return nil, fmt.Errorf("%s is not a known ld.so.cache format", path)
+	}
+
+	// Old format: header, entries, then strings, which might be
+	// followed by the new format aligned to 8 bytes.
+	nlibs := int(binary.NativeEndian.Uint32(data[12:16]))
+	end := cacheHeaderOld + nlibs*cacheEntryOld
+	if end > len(data) {
+		return nil, fmt.Errorf("%s is truncated", path)
+	}
+	offset := (end + 7) &^ 7
+	if offset < len(data) && bytes.HasPrefix(data[offset:], []byte(cacheMagicNew)) {
+		return parseCacheNew(data[offset:])
+	}
+
+	entries := []cacheEntry{}
+	strtab := data[end:]
+	for i := 0; i < nlibs; i++ {
+		entry := data[cacheHeaderOld+i*cacheEntryOld:]
+		flags := binary.NativeEndian.Uint32(entry[0:4])
+		key := binary.NativeEndian.Uint32(entry[4:8])
+		value := binary.NativeEndian.Uint32(entry[8:12])
+		entries = append(entries, cacheEntry{Soname: utils.CString(strtab, key), Path: utils.CString(strtab, value), Flags: flags})
+	}
+	return entries, nil
+}
+
+// parseCacheNew parses the new glibc cache format. String offsets are
+// relative to the start of the new header.
+func parseCacheNew(data []byte) ([]cacheEntry, error) {
+	if len(data) < cacheHeaderNew {
+		return nil, fmt.Errorf("ld.so.cache header is truncated")
+	}
+	nlibs := int(binary.NativeEndian.Uint32(data[20:24]))
+	if cacheHeaderNew+nlibs*cacheEntryNew > len(data) {
+		return nil, fmt.Errorf("ld.so.cache entries are truncated")
+	}
+	entries := []cacheEntry{}
+	for i := 0; i < nlibs; i++ {
+		entry := data[cacheHeaderNew+i*cacheEntryNew:]
+		flags := binary.NativeEndian.Uint32(entry[0:4])
+		key := binary.NativeEndian.Uint32(entry[4:8])
+		value := binary.NativeEndian.Uint32(entry[8:12])
+		entries = append(entries, cacheEntry{Soname: utils.CString(data, key), Path: utils.CString(data, value), Flags: flags})
+	}
+	return entries, nil
+}
@@ -0,0 +1,153 @@
+package inventory
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// newCache builds an ld.so.cache in the new glibc format. String offsets
+// are from the start of the header.
+func newCache(entries []cacheEntry) []byte {
+	data := make([]byte, cacheHeaderNew+len(entries)*cacheEntryNew)
+	copy(data, cacheMagicNew)
+	binary.NativeEndian.PutUint32(data[20:24], uint32(len(entries)))
+	return append(data, addEntries(data[cacheHeaderNew:], cacheEntryNew, len(data), entries)...)
+}
+
+// oldCache builds an ld.so.cache in the old format. String offsets are
+// from the end of the entries.
+func oldCache(entries []cacheEntry) []byte {
+	data := make([]byte, cacheHeaderOld+len(entries)*cacheEntryOld)
+	copy(data, cacheMagicOld)
+	binary.NativeEndian.PutUint32(data[12:16], uint32(len(entries)))
+	return append(data, addEntries(data[cacheHeaderOld:], cacheEntryOld, 0, entries)...)
+}
+
+// compatCache builds the old format header and entries, followed by the
+// new format aligned to 8 bytes (as ldconfig writes it)
+func compatCache(old, new []cacheEntry) []byte {
+	data := oldCache(old)[:cacheHeaderOld+len(old)*cacheEntryOld]
+	for len(data)%8 != 0 {
+		data = append(data, 0)
+	}
+	return append(data, newCache(new)...)
+}
+
+// addEntries writes entries of a size, with strings in a table at an
+// offset, and returns the table
+func addEntries(records []byte, size, offset int, entries []cacheEntry) []byte {
+	strtab := []byte{}
+	for i, entry := range entries {
+		record := records[i*size:]
+		binary.NativeEndian.PutUint32(record[0:4], entry.Flags)
+		binary.NativeEndian.PutUint32(record[4:8], uint32(offset+len(strtab)))
+		strtab = append(append(strtab, entry.Soname...), 0)
+		binary.NativeEndian.PutUint32(record[8:12], uint32(offset+len(strtab)))
+		strtab = append(append(strtab, entry.Path...), 0)
+	}
+	return strtab
+}
+
+func TestReadLdCache(t *testing.T) {
+	libc := cacheEntry{Soname: "libc.so.6", Path: "/lib/x86_64-linux-gnu/libc.so.6", Flags: 0x0303}
+	libc32 := cacheEntry{Soname: "libc.so.6", Path: "/lib/i386-linux-gnu/libc.so.6", Flags: 0x0003}
+	libm := cacheEntry{Soname: "libm.so.6", Path: "/lib/aarch64-linux-gnu/libm.so.6", Flags: 0x0a03}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		entries []cacheEntry
+		wantErr bool
+	}{
+		{"new format", newCache([]cacheEntry{libc, libc32, libm}), []cacheEntry{libc, libc32, libm}, false},
+		{"new format without entries", newCache(nil), []cacheEntry{}, false},
+		{"old format", oldCache([]cacheEntry{libc, libm}), []cacheEntry{libc, libm}, false},
+		{"old format followed by new", compatCache([]cacheEntry{libc32}, []cacheEntry{libc, libm}), []cacheEntry{libc, libm}, false},
+		{"unknown magic", []byte("not a cache at all"), nil, true},
+		{"truncated new header", []byte(cacheMagicNew), nil, true},
+		{"truncated new entries", newCache([]cacheEntry{libc})[:cacheHeaderNew+8], nil, true},
+		{"truncated old entries", oldCache([]cacheEntry{libc, libm})[:cacheHeaderOld+4], nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ld.so.cache")
+			err := os.WriteFile(path, test.data, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, err := readLdCache(path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("readLdCache() error = %v, want error %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(entries, test.entries) {
+				t.Errorf("readLdCache() = %v, want %v", entries, test.entries)
+			}
+		})
+	}
+}
+
+func TestCacheEntryMachine(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   uint32
+		machine string
+		class   string
+		ok      bool
+	}{
+		{"x86-64", 0x0303, "x86_64", "ELF64", true},
+		{"x32", 0x0803, "x86_64", "ELF32", true},
+		{"aarch64", 0x0a03, "aarch64", "ELF64", true},
+		{"arm hard float", 0x0903, "arm", "ELF32", true},
+		{"default ABI", 0x0003, "", "", false},
+		{"powerpc 64-bit", 0x0503, "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			machine, class, ok := cacheEntry{Flags: test.flags}.Machine()
+			if machine != test.machine || class != test.class || ok != test.ok {
+				t.Errorf("Machine() = %q, %q, %v, want %q, %q, %v", machine, class, ok, test.machine, test.class, test.ok)
+			}
+		})
+	}
+}
+
+func TestReadLdConf(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("ld.so.conf.d/a.conf", "/opt/a/lib # comment\n")
+	write("ld.so.conf.d/b.conf", "/opt/b/lib /opt/b/lib64\ninclude ../ld.so.conf\n")
+
+	tests := []struct {
+		name    string
+		content string
+		dirs    []string
+	}{
+		{"directories", "/usr/lib\n\n# only a comment\n/usr/local/lib\n", []string{"/usr/lib", "/usr/local/lib"}},
+		{"hwcap is ignored", "hwcap 0 nosegneg\n/usr/lib\n", []string{"/usr/lib"}},
+		{"include relative glob", "include ld.so.conf.d/*.conf\n/usr/lib\n", []string{"/opt/a/lib", "/opt/b/lib", "/opt/b/lib64", "/usr/lib"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dirs, err := readLdConf(write("ld.so.conf", test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dirs, test.dirs) {
+				t.Errorf("readLdConf() = %v, want %v", dirs, test.dirs)
+			}
+		})
+	}
+}
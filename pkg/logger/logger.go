@@ -1,30 +1,48 @@
 package logger
 
 import (
-	"fmt"
 	"log"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
 )
 
-// logger will record events for the recorder to file
-var (
-	logger *log.Logger
-	once   sync.Once
+// An EventLogger saves the events of a filesystem. Each filesystem is
+// given its own, so one process can record many runs.
+type EventLogger interface {
+	LogEvent(e *event.Event)
+	Close() error
 
-	// Default output file available for setting externally
-	Outfile string
-)
+	// Path of the file or directory events are written to
+	Path() string
+}
+
+// Logger writes events to one file
+type Logger struct {
+	writer event.Writer
+	mutex  sync.Mutex
+	path   string
+}
+
+var _ EventLogger = (*Logger)(nil)
+
+// NewLogger opens a file (appending if it exists) to write events in a
+// format (text, jsonl, or binary)
+func NewLogger(path, format string) (*Logger, error) {
+	writer, err := event.OpenFile(path, format)
+	if err != nil {
+		return nil, err
+	}
 
-// SetOutfile can be called from an external class to set
-// the global variable.
-func SetOutfile(outfile string) {
-	Outfile = outfile
+	// A temporary file is only readable by the user
+	os.Chmod(path, 0644)
+	return &Logger{writer: writer, path: path}, nil
 }
 
-// GetEventFile gets an event file
-func GetEventFile(outdir string) string {
+// GetEventFile gets an event file, named with an MPI rank if one is set
+func GetEventFile(outdir, rank string) string {
 
 	// If output directory not provided, default to pwd
 	if outdir == "" {
@@ -35,7 +53,11 @@ func GetEventFile(outdir string) string {
 		outdir = dir
 	}
 
-	f, err := os.CreateTemp(outdir, "fs-record.log")
+	pattern := "fs-record.log"
+	if rank != "" {
+		pattern = "fs-record.rank-" + rank + ".*.log"
+	}
+	f, err := os.CreateTemp(outdir, pattern)
 	if err != nil {
 		panic(err)
 	}
@@ -46,31 +68,36 @@ func GetEventFile(outdir string) string {
 	return tempFilePath
 }
 
-// logEvent logs the event to file with a unix nano timeseconds
-// and some arbitrary number of string arguments
-func LogEvent(args ...string) {
-	event, args := args[0], args[1:]
-
-	// Cut out early if we didn't define a log file
-	if Outfile == "" {
+// LogEvent logs the event to file, with a unix nano timestamp
+// if one is not set
+func (l *Logger) LogEvent(e *event.Event) {
+	if e.Timestamp == 0 {
+		e.Timestamp = time.Now().UnixNano()
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.writer == nil {
 		return
 	}
-	prefix := fmt.Sprintf("%d %-*s", time.Now().UnixNano(), 10, event)
-	for _, arg := range args {
-		prefix += fmt.Sprintf("%-*s", 10, arg)
+	err := l.writer.Write(e)
+	if err != nil {
+		log.Printf("warning: cannot record event: %s", err)
 	}
-	logger := getLogger()
-	logger.Println(prefix)
 }
 
-func getLogger() *log.Logger {
-	once.Do(func() {
-		file, err := os.OpenFile(Outfile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-		if err != nil {
-			log.Fatal(err)
-		}
-		logger = log.New(file, "", log.LstdFlags|log.Lshortfile)
-	})
-	log.SetFlags(0)
-	return logger
+// Path returns the file events are written to
+func (l *Logger) Path() string {
+	return l.path
+}
+
+// Close closes the output file, after which events are discarded
+func (l *Logger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.writer == nil {
+		return nil
+	}
+	err := l.writer.Close()
+	l.writer = nil
+	return err
 }
@@ -0,0 +1,127 @@
+package mpi
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Environment variables set by MPI launchers and schedulers, for Open
+// MPI, MPICH and Intel MPI (PMI or hydra), Slurm and Flux, in that order
+var (
+	rankNames      = []string{"OMPI_COMM_WORLD_RANK", "PMI_RANK", "SLURM_PROCID", "FLUX_TASK_RANK"}
+	localRankNames = []string{"OMPI_COMM_WORLD_LOCAL_RANK", "MPI_LOCALRANKID", "SLURM_LOCALID", "FLUX_TASK_LOCAL_ID"}
+	localSizeNames = []string{"OMPI_COMM_WORLD_LOCAL_SIZE", "MPI_LOCALNRANKS"}
+	jobNames       = []string{"OMPI_MCA_ess_base_jobid", "PMI_JOBID", "SLURM_JOB_ID", "FLUX_JOB_ID"}
+
+	// A Slurm job can run many steps (e.g., srun calls) with the same job id
+	stepNames = []string{"SLURM_STEP_ID"}
+)
+
+// A rank in a file or directory name (e.g., lammps.rank-3.out)
+var pathRank = regexp.MustCompile(`rank-(\d+)`)
+
+// Rank returns the rank of this process (empty if it was not launched
+// with MPI)
+func Rank() string {
+	return lookup(os.Getenv, rankNames)
+}
+
+// LocalRank returns the rank of this process among those on its node
+func LocalRank() string {
+	return lookup(os.Getenv, localRankNames)
+}
+
+// LocalSize returns the number of ranks on this node (0 if unknown)
+func LocalSize() int {
+	size, err := strconv.Atoi(lookup(os.Getenv, localSizeNames))
+	if err == nil {
+		return size
+	}
+	node, err := strconv.Atoi(os.Getenv("SLURM_NODEID"))
+	if err != nil {
+		return 0
+	}
+	return slurmTasks(os.Getenv("SLURM_STEP_TASKS_PER_NODE"), node)
+}
+
+// JobID returns the id of the job (with its step, for Slurm), which the
+// ranks of a job share
+func JobID() string {
+	job := lookup(os.Getenv, jobNames)
+	step := lookup(os.Getenv, stepNames)
+	if job == "" || step == "" {
+		return job
+	}
+	return job + "." + step
+}
+
+// ProcessRank returns the rank of a process from its environment, which
+// its children inherit (empty if it has none or cannot be read)
+func ProcessRank(pid int) string {
+	environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return ""
+	}
+	env := map[string]string{}
+	for _, item := range bytes.Split(environ, []byte{0}) {
+		name, value, _ := strings.Cut(string(item), "=")
+		env[name] = value
+	}
+	return lookup(func(name string) string { return env[name] }, rankNames)
+}
+
+// RankPath names a file for a rank, before its extension (e.g.,
+// lammps.out is lammps.rank-3.out)
+func RankPath(path, rank string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".rank-" + rank + ext
+}
+
+// PathRank returns the rank in the name of a file or one of its
+// directories (empty if there is none)
+func PathRank(path string) string {
+	matches := pathRank.FindAllStringSubmatch(path, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1][1]
+}
+
+// lookup returns the first variable that is set
+func lookup(getenv func(string) string, names []string) string {
+	for _, name := range names {
+		if value := getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// slurmTasks returns the tasks on a node from the tasks per node of a
+// step, e.g., 2(x3),1 for three nodes with two tasks and one with one
+func slurmTasks(value string, node int) int {
+	for _, group := range strings.Split(value, ",") {
+		count, repeat := group, "1"
+		if idx := strings.Index(group, "(x"); idx >= 0 {
+			count, repeat = group[:idx], strings.TrimSuffix(group[idx+2:], ")")
+		}
+		tasks, err := strconv.Atoi(count)
+		if err != nil {
+			return 0
+		}
+		nodes, err := strconv.Atoi(repeat)
+		if err != nil {
+			return 0
+		}
+		if node < nodes {
+			return tasks
+		}
+		node -= nodes
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package mpi
+
+import (
+	"testing"
+)
+
+// setenv sets the launcher variables of a test, and unsets the others so
+// the environment of the test run cannot change the result
+func setenv(t *testing.T, env map[string]string) {
+	for _, names := range [][]string{rankNames, localRankNames, localSizeNames, jobNames, stepNames} {
+		for _, name := range names {
+			t.Setenv(name, "")
+		}
+	}
+	t.Setenv("SLURM_NODEID", "")
+	t.Setenv("SLURM_STEP_TASKS_PER_NODE", "")
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+func TestRank(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		rank string
+	}{
+		{name: "no launcher"},
+		{name: "open mpi", env: map[string]string{"OMPI_COMM_WORLD_RANK": "3"}, rank: "3"},
+		{name: "mpich", env: map[string]string{"PMI_RANK": "1"}, rank: "1"},
+		{name: "slurm", env: map[string]string{"SLURM_PROCID": "7"}, rank: "7"},
+		{name: "flux", env: map[string]string{"FLUX_TASK_RANK": "0"}, rank: "0"},
+
+		// mpirun inside a Slurm allocation sets both, and the rank of the
+		// launcher is the rank in the job
+		{
+			name: "open mpi under slurm",
+			env:  map[string]string{"OMPI_COMM_WORLD_RANK": "5", "SLURM_PROCID": "0"},
+			rank: "5",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, test.env)
+			if rank := Rank(); rank != test.rank {
+				t.Errorf("Rank() = %q, want %q", rank, test.rank)
+			}
+		})
+	}
+}
+
+func TestLocalSize(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		size int
+	}{
+		{name: "unknown"},
+		{name: "open mpi", env: map[string]string{"OMPI_COMM_WORLD_LOCAL_SIZE": "4"}, size: 4},
+		{
+			name: "slurm first node",
+			env:  map[string]string{"SLURM_NODEID": "0", "SLURM_STEP_TASKS_PER_NODE": "2(x3),1"},
+			size: 2,
+		},
+		{
+			name: "slurm last node",
+			env:  map[string]string{"SLURM_NODEID": "3", "SLURM_STEP_TASKS_PER_NODE": "2(x3),1"},
+			size: 1,
+		},
+		{
+			name: "slurm node past the step",
+			env:  map[string]string{"SLURM_NODEID": "4", "SLURM_STEP_TASKS_PER_NODE": "2(x3),1"},
+		},
+		{
+			name: "slurm tasks not a number",
+			env:  map[string]string{"SLURM_NODEID": "0", "SLURM_STEP_TASKS_PER_NODE": "two"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, test.env)
+			if size := LocalSize(); size != test.size {
+				t.Errorf("LocalSize() = %d, want %d", size, test.size)
+			}
+		})
+	}
+}
+
+func TestJobID(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		job  string
+	}{
+		{name: "no job"},
+		{name: "flux", env: map[string]string{"FLUX_JOB_ID": "f5W8gVo"}, job: "f5W8gVo"},
+		{name: "slurm step", env: map[string]string{"SLURM_JOB_ID": "812", "SLURM_STEP_ID": "2"}, job: "812.2"},
+
+		// A step without a job is not enough to tell jobs apart
+		{name: "step alone", env: map[string]string{"SLURM_STEP_ID": "2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setenv(t, test.env)
+			if job := JobID(); job != test.job {
+				t.Errorf("JobID() = %q, want %q", job, test.job)
+			}
+		})
+	}
+}
+
+func TestPathRank(t *testing.T) {
+	tests := []struct {
+		path string
+		rank string
+	}{
+		{path: "lammps.rank-3.out", rank: "3"},
+		{path: "/tmp/run/rank-12/fs.log", rank: "12"},
+
+		// The file is named for its rank inside a directory for another
+		{path: "/tmp/rank-1/lammps.rank-4.log", rank: "4"},
+		{path: "lammps.out"},
+		{path: "rank-x.log"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			if rank := PathRank(test.path); rank != test.rank {
+				t.Errorf("PathRank(%q) = %q, want %q", test.path, rank, test.rank)
+			}
+		})
+	}
+}
+
+func TestRankPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"lammps.out":      "lammps.rank-3.out",
+		"/tmp/run/fs.log": "/tmp/run/fs.rank-3.log",
+		"events":          "events.rank-3",
+	} {
+		if got := RankPath(path, "3"); got != want {
+			t.Errorf("RankPath(%q) = %q, want %q", path, got, want)
+		}
+		if rank := PathRank(RankPath(path, "3")); rank != "3" {
+			t.Errorf("PathRank(RankPath(%q)) = %q, want 3", path, rank)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package mpi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Files in the directory that ranks sharing a mount use to coordinate
+const (
+	readyFile    = "ready"
+	donePrefix   = "done-"
+	failedPrefix = "failed-"
+
+	// How often to check for the lead rank or the others
+	pollInterval = 100 * time.Millisecond
+)
+
+// Shared coordinates the ranks on a node that share one mount. The lead
+// rank mounts and records, and the others run their commands in its mount
+// once it is ready. The lead keeps the mount until they are all done.
+// They coordinate with files in a directory next to the mount, marked
+// with the job so one left by an earlier job is not used. A rank that
+// fails (even before the lead is ready) marks that instead of done.
+type Shared struct {
+	dir string
+	job string
+}
+
+// NewShared returns the coordination for a shared mount
+func NewShared(mountPath string) *Shared {
+	return &Shared{
+		dir: filepath.Clean(mountPath) + ".ranks",
+		job: JobID(),
+	}
+}
+
+// Ready is called by the lead rank when the mount is ready. Files left by
+// other jobs are removed, and those of ranks of this job are kept.
+func (s *Shared) Ready() error {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(s.dir, entry.Name())
+
+		// A file being written by a rank can still be empty
+		job, err := os.ReadFile(path)
+		if err == nil && (len(job) == 0 || string(job) == s.job) {
+			continue
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return s.mark(readyFile)
+}
+
+// WaitReady waits for the lead rank of this job to be ready
+func (s *Shared) WaitReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		job, err := os.ReadFile(filepath.Join(s.dir, readyFile))
+		if err == nil && string(job) == s.job {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the lead rank did not mount after %s (see %s)", timeout, s.dir)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Done is called by a rank when its command is done
+func (s *Shared) Done(rank string) error {
+	return s.mark(donePrefix + rank)
+}
+
+// Failed is called by a rank that cannot run its command, or whose
+// command failed
+func (s *Shared) Failed(rank string) error {
+	return s.mark(failedPrefix + rank)
+}
+
+// mark writes a file marked with the job
+func (s *Shared) mark(name string) error {
+	err := os.MkdirAll(s.dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, name), []byte(s.job), 0644)
+}
+
+// WaitDone waits for a number of ranks to be done (or failed), for the
+// timeout, or for stop to close. It returns the ranks that failed.
+func (s *Shared) WaitDone(count int, timeout time.Duration, stop <-chan struct{}) ([]string, error) {
+	deadline := time.After(timeout)
+	for {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			return nil, err
+		}
+		done := 0
+		failed := []string{}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, donePrefix) && !strings.HasPrefix(name, failedPrefix) {
+				continue
+			}
+			job, err := os.ReadFile(filepath.Join(s.dir, name))
+			if err != nil || string(job) != s.job {
+				continue
+			}
+			done++
+			if rank, ok := strings.CutPrefix(name, failedPrefix); ok {
+				failed = append(failed, rank)
+			}
+		}
+		if done >= count {
+			return failed, nil
+		}
+		select {
+		case <-stop:
+			return failed, fmt.Errorf("stopped waiting with %d of %d ranks done", done, count)
+		case <-deadline:
+			return failed, fmt.Errorf("%d of %d ranks were not done after %s (see %s)", count-done, count, timeout, s.dir)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Remove removes the files used to coordinate
+func (s *Shared) Remove() error {
+	return os.RemoveAll(s.dir)
+}
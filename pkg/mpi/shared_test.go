@@ -0,0 +1,169 @@
+package mpi
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newShared returns the coordination of job 812.2 in a temporary directory
+func newShared(t *testing.T) *Shared {
+	return &Shared{dir: filepath.Join(t.TempDir(), "mnt.ranks"), job: "812.2"}
+}
+
+// other marks a file as another job would have left it
+func other(s *Shared, name string) {
+	(&Shared{dir: s.dir, job: "811.0"}).mark(name)
+}
+
+func TestNewShared(t *testing.T) {
+	setenv(t, map[string]string{"SLURM_JOB_ID": "812", "SLURM_STEP_ID": "2"})
+	s := NewShared("/tmp/run/mnt/")
+	if s.dir != "/tmp/run/mnt.ranks" || s.job != "812.2" {
+		t.Errorf("NewShared() = %+v, want /tmp/run/mnt.ranks for 812.2", s)
+	}
+}
+
+func TestReady(t *testing.T) {
+	s := newShared(t)
+	other(s, readyFile)
+	other(s, donePrefix+"1")
+	s.Done("2")
+
+	// A rank that has created its file but not written it yet
+	os.WriteFile(filepath.Join(s.dir, failedPrefix+"3"), nil, 0644)
+
+	err := s.WaitReady(0)
+	if err == nil {
+		t.Fatal("WaitReady() found the lead rank of another job")
+	}
+	err = s.Ready()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.WaitReady(0)
+	if err != nil {
+		t.Errorf("WaitReady() = %s after Ready()", err)
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{donePrefix + "2", failedPrefix + "3", readyFile}
+	if !slices.Equal(names, want) {
+		t.Errorf("Ready() left %v, want %v", names, want)
+	}
+}
+
+func TestWaitDone(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		mark  func(s *Shared)
+		stop  bool
+
+		failed []string
+		err    string
+	}{
+		{
+			name:  "done",
+			count: 2,
+			mark: func(s *Shared) {
+				s.Done("0")
+				s.Done("1")
+			},
+			failed: []string{},
+		},
+		{
+			name:  "failed rank",
+			count: 3,
+			mark: func(s *Shared) {
+				s.Done("0")
+				s.Failed("1")
+				s.Failed("2")
+			},
+			failed: []string{"1", "2"},
+		},
+		{
+			name:  "timeout",
+			count: 3,
+			mark: func(s *Shared) {
+				s.Done("0")
+				s.Failed("1")
+			},
+			failed: []string{"1"},
+			err:    "1 of 3 ranks were not done",
+		},
+		{
+			name:  "done by another job",
+			count: 2,
+			mark: func(s *Shared) {
+				s.Done("0")
+				other(s, donePrefix+"1")
+			},
+			failed: []string{},
+			err:    "1 of 2 ranks were not done",
+		},
+		{
+			name:  "stopped",
+			count: 2,
+			mark: func(s *Shared) {
+				s.Done("0")
+			},
+			stop:   true,
+			failed: []string{},
+			err:    "stopped waiting with 1 of 2 ranks done",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newShared(t)
+			test.mark(s)
+			stop := make(chan struct{})
+			if test.stop {
+				close(stop)
+			}
+
+			// Ranks that are not done keep the wait to its timeout
+			timeout := 10 * time.Millisecond
+			if test.stop {
+				timeout = time.Minute
+			}
+			failed, err := s.WaitDone(test.count, timeout, stop)
+			if !slices.Equal(failed, test.failed) {
+				t.Errorf("WaitDone() failed %v, want %v", failed, test.failed)
+			}
+			if test.err == "" && err != nil {
+				t.Errorf("WaitDone() = %s", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Errorf("WaitDone() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+// A rank that is done while the lead waits is found at the next poll
+func TestWaitDoneLate(t *testing.T) {
+	s := newShared(t)
+	s.Done("0")
+	go func() {
+		time.Sleep(pollInterval / 2)
+		s.Failed("1")
+	}()
+	failed, err := s.WaitDone(2, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(failed, []string{"1"}) {
+		t.Errorf("WaitDone() failed %v, want [1]", failed)
+	}
+}
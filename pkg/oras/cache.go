@@ -0,0 +1,352 @@
+package oras
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/utils"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// Files and directories in the cache root
+	cacheIndexFile = "index.json"
+	cacheLockFile  = "index.lock"
+	cacheBlobsDir  = "blobs"
+
+	// Access times are only written when they are older than this, so
+	// each hit does not rewrite the index
+	accessInterval = time.Minute
+)
+
+// CacheOptions control expiry and eviction in the cache
+type CacheOptions struct {
+
+	// How long a tag to digest mapping is trusted before revalidating
+	// with the registry (0 always revalidates)
+	TTL time.Duration
+
+	// Maximum total size of cached content in bytes (0 is unlimited)
+	MaxSize int64
+}
+
+// A Cache stores compatibility spec layers by content digest, with an
+// index from manifest digest to entry and from tag to manifest digest.
+// The index is locked while it is read and written, within the process
+// and with a file lock for others (e.g., compat-cache and a server).
+type Cache struct {
+	Root    string
+	Options CacheOptions
+	mutex   sync.Mutex
+}
+
+// CacheIndex is the json index in the root of the cache
+type CacheIndex struct {
+	Tags    map[string]*TagEntry   `json:"tags"`
+	Entries map[string]*CacheEntry `json:"entries"`
+}
+
+// TagEntry maps a tagged reference to a manifest digest
+type TagEntry struct {
+	Digest   digest.Digest `json:"digest"`
+	Resolved time.Time     `json:"resolved"`
+}
+
+// CacheEntry is a cached spec for a manifest digest
+type CacheEntry struct {
+	Digest   digest.Digest `json:"digest"`
+	Layer    digest.Digest `json:"layer"`
+	Size     int64         `json:"size"`
+	Created  time.Time     `json:"created"`
+	Accessed time.Time     `json:"accessed"`
+
+	// Fingerprint of the key the signature was verified with
+	Signer string `json:"signer,omitempty"`
+}
+
+// NewCache returns a cache at a root, creating it if needed
+func NewCache(root string, options CacheOptions) (*Cache, error) {
+	err := os.MkdirAll(filepath.Join(root, cacheBlobsDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{Root: root, Options: options}, nil
+}
+
+// blobPath returns the path for content with a digest
+func (c *Cache) blobPath(dgst digest.Digest) string {
+	return filepath.Join(c.Root, cacheBlobsDir, dgst.Algorithm().String(), dgst.Encoded())
+}
+
+// lock takes the cache, shared (to read the index) or exclusive (to
+// change it), and returns a function to release it
+func (c *Cache) lock(how int) (func(), error) {
+	c.mutex.Lock()
+	fd, err := os.OpenFile(filepath.Join(c.Root, cacheLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		c.mutex.Unlock()
+		return nil, err
+	}
+	err = syscall.Flock(int(fd.Fd()), how)
+	if err != nil {
+		fd.Close()
+		c.mutex.Unlock()
+		return nil, err
+	}
+
+	// Closing the file releases the lock
+	return func() {
+		fd.Close()
+		c.mutex.Unlock()
+	}, nil
+}
+
+// ReadIndex reads the cache index (empty if it does not exist). It is not
+// locked, but the index is replaced atomically, so it is a snapshot.
+func (c *Cache) ReadIndex() (*CacheIndex, error) {
+	index := CacheIndex{Tags: map[string]*TagEntry{}, Entries: map[string]*CacheEntry{}}
+	data, err := os.ReadFile(filepath.Join(c.Root, cacheIndexFile))
+	if os.IsNotExist(err) {
+		return &index, nil
+	}
+	if err != nil {
+		return &index, err
+	}
+	err = json.Unmarshal(data, &index)
+	if index.Tags == nil {
+		index.Tags = map[string]*TagEntry{}
+	}
+	if index.Entries == nil {
+		index.Entries = map[string]*CacheEntry{}
+	}
+	return &index, err
+}
+
+// writeIndex writes the cache index atomically
+func (c *Cache) writeIndex(index *CacheIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(c.Root, cacheIndexFile), data)
+}
+
+// Resolve returns the manifest digest for a tagged reference, and if the
+// mapping is still within the TTL (and does not need revalidation)
+func (c *Cache) Resolve(reference string) (digest.Digest, bool, error) {
+	unlock, err := c.lock(syscall.LOCK_SH)
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return "", false, err
+	}
+	tag, ok := index.Tags[reference]
+	if !ok {
+		return "", false, nil
+	}
+	return tag.Digest, time.Since(tag.Resolved) < c.Options.TTL, nil
+}
+
+// Tag records the manifest digest a tagged reference resolved to
+func (c *Cache) Tag(reference string, dgst digest.Digest) error {
+	unlock, err := c.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return err
+	}
+	index.Tags[reference] = &TagEntry{Digest: dgst, Resolved: time.Now()}
+	return c.writeIndex(index)
+}
+
+// Get returns the cached content and entry for a manifest digest
+// The entry is nil if the digest is not cached. The access time (for
+// eviction) is updated at most once per accessInterval.
+func (c *Cache) Get(dgst digest.Digest) ([]byte, *CacheEntry, error) {
+	unlock, err := c.lock(syscall.LOCK_EX)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry, ok := index.Entries[dgst.String()]
+	if !ok {
+		return nil, nil, nil
+	}
+	data, err := os.ReadFile(c.blobPath(entry.Layer))
+	if os.IsNotExist(err) {
+		delete(index.Entries, dgst.String())
+		return nil, nil, c.writeIndex(index)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if time.Since(entry.Accessed) < accessInterval {
+		return data, entry, nil
+	}
+	entry.Accessed = time.Now()
+	return data, entry, c.writeIndex(index)
+}
+
+// Put adds content (a spec layer) for a manifest digest, and evicts the
+// least recently used entries if the cache is over the maximum size.
+// The signer is the fingerprint of the key that verified it (if any).
+func (c *Cache) Put(dgst, layer digest.Digest, data []byte, signer string) error {
+	if layer.Validate() != nil || digest.FromBytes(data) != layer {
+		return fmt.Errorf("content does not match layer digest %s", layer)
+	}
+	unlock, err := c.lock(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return err
+	}
+	path := c.blobPath(layer)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	err = utils.WriteFileAtomic(path, data)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	index.Entries[dgst.String()] = &CacheEntry{
+		Digest:   dgst,
+		Layer:    layer,
+		Size:     int64(len(data)),
+		Created:  now,
+		Accessed: now,
+		Signer:   signer,
+	}
+	c.evict(index)
+	return c.writeIndex(index)
+}
+
+// List returns entries, most recently used first
+func (c *Cache) List() ([]*CacheEntry, error) {
+	unlock, err := c.lock(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	entries := sortedEntries(index)
+	return entries, nil
+}
+
+// Prune removes entries not accessed within a duration, then evicts to
+// the maximum size. Tags that point to removed entries are kept, since
+// they only save a lookup. Removed entries are returned.
+func (c *Cache) Prune(olderThan time.Duration) ([]*CacheEntry, error) {
+	unlock, err := c.lock(syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	removed := []*CacheEntry{}
+	for key, entry := range index.Entries {
+		if olderThan > 0 && time.Since(entry.Accessed) > olderThan {
+			removed = append(removed, entry)
+			delete(index.Entries, key)
+		}
+	}
+	removed = append(removed, c.evict(index)...)
+	c.removeUnreferenced(index)
+	return removed, c.writeIndex(index)
+}
+
+// Verify checks that each entry's content matches its digest. Entries
+// that are missing or corrupt are removed, and returned.
+func (c *Cache) Verify() ([]*CacheEntry, error) {
+	unlock, err := c.lock(syscall.LOCK_EX)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	index, err := c.ReadIndex()
+	if err != nil {
+		return nil, err
+	}
+	invalid := []*CacheEntry{}
+	for key, entry := range index.Entries {
+		data, err := os.ReadFile(c.blobPath(entry.Layer))
+		if err != nil || digest.FromBytes(data) != entry.Layer {
+			invalid = append(invalid, entry)
+			delete(index.Entries, key)
+		}
+	}
+	c.removeUnreferenced(index)
+	return invalid, c.writeIndex(index)
+}
+
+// evict removes least recently used entries until under the maximum size
+func (c *Cache) evict(index *CacheIndex) []*CacheEntry {
+	removed := []*CacheEntry{}
+	if c.Options.MaxSize <= 0 {
+		return removed
+	}
+	total := int64(0)
+	entries := sortedEntries(index)
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	for i := len(entries) - 1; i >= 0 && total > c.Options.MaxSize; i-- {
+		total -= entries[i].Size
+		removed = append(removed, entries[i])
+		delete(index.Entries, entries[i].Digest.String())
+	}
+	c.removeUnreferenced(index)
+	return removed
+}
+
+// removeUnreferenced deletes blobs that no entry refers to
+func (c *Cache) removeUnreferenced(index *CacheIndex) {
+	referenced := map[string]bool{}
+	for _, entry := range index.Entries {
+		referenced[c.blobPath(entry.Layer)] = true
+	}
+	filepath.Walk(filepath.Join(c.Root, cacheBlobsDir), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !referenced[path] {
+			os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// sortedEntries returns entries sorted by most recent access
+func sortedEntries(index *CacheIndex) []*CacheEntry {
+	entries := []*CacheEntry{}
+	for _, entry := range index.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Accessed.After(entries[j].Accessed)
+	})
+	return entries
+}
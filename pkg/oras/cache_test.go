@@ -0,0 +1,93 @@
+package oras
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// TestCacheConcurrentTags changes the index from caches that share a root,
+// like compat-cache and a server, which must not lose each other's updates
+func TestCacheConcurrentTags(t *testing.T) {
+	root := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		cache, err := NewCache(root, CacheOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				reference := fmt.Sprintf("registry/spec:%d-%d", i, j)
+				err := cache.Tag(reference, digest.FromString(reference))
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	cache, _ := NewCache(root, CacheOptions{})
+	index, err := cache.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Tags) != 100 {
+		t.Errorf("index has %d tags, want 100", len(index.Tags))
+	}
+}
+
+func TestCacheGetAccess(t *testing.T) {
+	data := []byte(`{"version": "0.1.0"}`)
+	manifest, layer := digest.FromString("manifest"), digest.FromBytes(data)
+
+	tests := []struct {
+		name    string
+		age     time.Duration
+		written bool
+	}{
+		{"recent access is not written", time.Second, false},
+		{"old access is written", 2 * accessInterval, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache, err := NewCache(t.TempDir(), CacheOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = cache.Put(manifest, layer, data, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			index, _ := cache.ReadIndex()
+			accessed := time.Now().Add(-test.age)
+			index.Entries[manifest.String()].Accessed = accessed
+			err = cache.writeIndex(index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before, _ := os.Stat(filepath.Join(cache.Root, cacheIndexFile))
+
+			content, entry, err := cache.Get(manifest)
+			if err != nil || entry == nil || string(content) != string(data) {
+				t.Fatalf("Get() = %q, %v, %v", content, entry, err)
+			}
+			after, _ := os.Stat(filepath.Join(cache.Root, cacheIndexFile))
+			if written := !os.SameFile(before, after); written != test.written {
+				t.Errorf("index written = %v, want %v", written, test.written)
+			}
+			index, _ = cache.ReadIndex()
+			if updated := index.Entries[manifest.String()].Accessed.After(accessed); updated != test.written {
+				t.Errorf("access updated = %v, want %v", updated, test.written)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package oras
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	orasgo "oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	ocilayout "oras.land/oras-go/v2/content/oci"
+)
+
+const (
+	// Prefixes for whiteout files in image layers
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+
+	// Docker equivalent of an image index
+	dockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// HostPlatform returns the platform of the running host
+func HostPlatform() *oci.Platform {
+	return &oci.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+}
+
+// isIndex determines if a media type is an image index (or manifest list)
+func isIndex(mediaType string) bool {
+	return mediaType == oci.MediaTypeImageIndex || mediaType == dockerManifestList
+}
+
+// resolveManifest resolves a reference to a manifest. If the reference is
+// an image index, the manifest for the platform is selected.
+func resolveManifest(ctx context.Context, target orasgo.ReadOnlyTarget, reference string, platform *oci.Platform) (oci.Descriptor, error) {
+	desc, err := target.Resolve(ctx, reference)
+	if err != nil || !isIndex(desc.MediaType) {
+		return desc, err
+	}
+	return orasgo.Resolve(ctx, target, reference, orasgo.ResolveOptions{TargetPlatform: platform})
+}
+
+// PullImage pulls an image from a registry into an OCI image layout,
+// selecting the manifest for the host platform, and returns the reference
+// (tag or digest) it is stored under in the layout
+func PullImage(uri, layoutPath string, opts RegistryOptions) (string, error) {
+	ctx := context.Background()
+	ref, err := ParseReference(uri)
+	if err != nil {
+		return "", err
+	}
+	repo, err := opts.newRepository(ref)
+	if err != nil {
+		return "", err
+	}
+	store, err := ocilayout.New(layoutPath)
+	if err != nil {
+		return "", err
+	}
+	copyOpts := orasgo.CopyOptions{}
+	copyOpts.WithTargetPlatform(HostPlatform())
+	_, err = orasgo.Copy(ctx, repo, ref.Reference, store, ref.Reference, copyOpts)
+	return ref.Reference, err
+}
+
+// UnpackImage unpacks the layers of an image in an OCI image layout
+// into a rootfs directory, applying whiteouts between layers
+func UnpackImage(layoutPath, reference, dest string) error {
+	ctx := context.Background()
+	store, err := ocilayout.New(layoutPath)
+	if err != nil {
+		return err
+	}
+	desc, err := resolveManifest(ctx, store, reference, HostPlatform())
+	if err != nil {
+		return err
+	}
+	manifestBytes, err := content.FetchAll(ctx, store, desc)
+	if err != nil {
+		return err
+	}
+	var manifest oci.Manifest
+	err = json.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		return err
+	}
+	for _, layer := range manifest.Layers {
+		if !strings.Contains(layer.MediaType, "tar") {
+			continue
+		}
+		reader, err := store.Fetch(ctx, layer)
+		if err != nil {
+			return err
+		}
+		err = unpackLayer(reader, dest)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("cannot unpack layer %s: %w", layer.Digest, err)
+		}
+	}
+	return nil
+}
+
+// unpackLayer extracts a (possibly gzipped) tar layer into a directory
+func unpackLayer(reader io.Reader, dest string) error {
+	buffered := bufio.NewReader(reader)
+	magic, err := buffered.Peek(2)
+	if err != nil {
+		return err
+	}
+	reader = buffered
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	// Whiteouts hide what lower layers wrote, not what this layer wrote
+	written := map[string]bool{}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Clean the path so it cannot escape the destination
+		name := filepath.Clean("/" + header.Name)
+		target := filepath.Join(dest, name)
+		parent := filepath.Dir(target)
+		if !withinRoot(dest, parent) {
+			log.Printf("warning: skipping %s that resolves outside of the rootfs", name)
+			continue
+		}
+
+		base := filepath.Base(name)
+		if base == whiteoutOpaque {
+			hideLower(parent, written)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			hidden := strings.TrimPrefix(base, whiteoutPrefix)
+			if hidden == "" || hidden == "." || hidden == ".." || strings.ContainsRune(hidden, filepath.Separator) {
+				log.Printf("warning: skipping invalid whiteout %s", name)
+				continue
+			}
+
+			// RemoveAll does not follow a symlink at the path itself, so
+			// only the directory it is in must resolve within the rootfs
+			path := filepath.Join(parent, hidden)
+			if !withinRoot(dest, filepath.Dir(path)) {
+				log.Printf("warning: skipping whiteout %s that resolves outside of the rootfs", name)
+				continue
+			}
+			if !written[path] {
+				os.RemoveAll(path)
+			}
+			continue
+		}
+
+		err = os.MkdirAll(parent, 0755)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, os.FileMode(header.Mode&0777)|0700)
+		case tar.TypeReg:
+			err = writeFile(target, tr, os.FileMode(header.Mode&0777)|0200)
+		case tar.TypeSymlink:
+			os.RemoveAll(target)
+			err = os.Symlink(header.Linkname, target)
+		case tar.TypeLink:
+			source := filepath.Join(dest, filepath.Clean("/"+header.Linkname))
+			if !withinRoot(dest, filepath.Dir(source)) {
+				continue
+			}
+			os.RemoveAll(target)
+			err = os.Link(source, target)
+		}
+
+		// Devices, fifos, etc. are not needed to find binaries
+		if err != nil {
+			return err
+		}
+		for path := target; path != filepath.Clean(dest) && !written[path]; path = filepath.Dir(path) {
+			written[path] = true
+		}
+	}
+}
+
+// hideLower removes the entries of a directory from lower layers (an opaque
+// whiteout), keeping those written by the current layer. A directory the
+// layer wrote into keeps only what the layer wrote in it.
+func hideLower(dir string, written map[string]bool) {
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !written[path] {
+			os.RemoveAll(path)
+		} else if entry.IsDir() {
+			hideLower(path, written)
+		}
+	}
+}
+
+// writeFile writes content to a path, replacing what is there
+func writeFile(path string, reader io.Reader, mode os.FileMode) error {
+	os.RemoveAll(path)
+	fd, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	_, err = io.Copy(fd, reader)
+	return err
+}
+
+// withinRoot determines if a path (following symlinks that exist) is under root
+func withinRoot(root, path string) bool {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+
+	// Walk up to the deepest part of the path that exists
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(resolvedRoot, resolved)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
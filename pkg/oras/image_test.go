@@ -0,0 +1,115 @@
+package oras
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// layer builds an uncompressed tar layer. Names ending in / are
+// directories, and the rest are files with their name as content.
+func layer(t *testing.T, names ...string) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(name))}
+		if strings.HasSuffix(name, "/") {
+			header = &tar.Header{Name: name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(name))
+		}
+	}
+	tw.Close()
+	return buf
+}
+
+// tree lists the files and directories under a directory
+func tree(t *testing.T, root string) []string {
+	t.Helper()
+	paths := []string{}
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root {
+			rel, _ := filepath.Rel(root, path)
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths
+}
+
+func TestUnpackLayerWhiteouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		lower  []string
+		upper  []string
+		rootfs []string
+	}{
+		{
+			name:   "whiteout removes a lower file",
+			lower:  []string{"etc/", "etc/a", "etc/b"},
+			upper:  []string{"etc/.wh.a"},
+			rootfs: []string{"etc", "etc/b"},
+		},
+		{
+			name:   "whiteout of the parent directory is ignored",
+			lower:  []string{"etc/", "etc/a"},
+			upper:  []string{".wh...", "etc/.wh..."},
+			rootfs: []string{"etc", "etc/a"},
+		},
+		{
+			name:   "whiteout of the directory itself is ignored",
+			lower:  []string{"etc/", "etc/a"},
+			upper:  []string{".wh..", "etc/.wh."},
+			rootfs: []string{"etc", "etc/a"},
+		},
+		{
+			name:   "opaque whiteout keeps what the layer wrote",
+			lower:  []string{"etc/", "etc/a", "etc/sub/", "etc/sub/old"},
+			upper:  []string{"etc/new", "etc/sub/added", "etc/.wh..wh..opq"},
+			rootfs: []string{"etc", "etc/new", "etc/sub", "etc/sub/added"},
+		},
+		{
+			name:   "whiteout does not remove a file of the same layer",
+			lower:  []string{"etc/"},
+			upper:  []string{"etc/a", "etc/.wh.a"},
+			rootfs: []string{"etc", "etc/a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			// A sibling of the rootfs must survive any layer
+			parent := t.TempDir()
+			sibling := filepath.Join(parent, "sibling")
+			os.WriteFile(sibling, []byte("keep"), 0644)
+			dest := filepath.Join(parent, "rootfs")
+			os.Mkdir(dest, 0755)
+
+			for _, names := range [][]string{test.lower, test.upper} {
+				if err := unpackLayer(layer(t, names...), dest); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if _, err := os.Stat(sibling); err != nil {
+				t.Fatalf("sibling of the rootfs was removed: %s", err)
+			}
+			got := tree(t, dest)
+			if strings.Join(got, ",") != strings.Join(test.rootfs, ",") {
+				t.Errorf("rootfs is %v, want %v", got, test.rootfs)
+			}
+		})
+	}
+}
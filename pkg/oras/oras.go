@@ -6,199 +6,272 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
-	"strings"
+	"log"
 
 	"github.com/compspec/compat-lib/pkg/compat"
-	"github.com/compspec/compat-lib/pkg/utils"
+	"github.com/opencontainers/go-digest"
 	oci "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
 	"oras.land/oras-go/v2/registry/remote"
 	"sigs.k8s.io/yaml"
 )
 
-// toFilename converts the uri of an image to a filename
-func toFilename(uri string) string {
-	for _, repl := range []string{"/", ":"} {
-		uri = strings.ReplaceAll(uri, repl, "-")
-	}
-	return fmt.Sprintf("%s.json", uri)
-}
+const (
+	// Media type for a compatibility spec layer
+	CompatibilityMediaType = "application/vnd.llnl.compatlib.spec.v1+json"
+)
 
-// LoadFromCache loads the compatibility request from cache
-func LoadFromCache(uri, cache string) (*compat.CompatibiitySpec, error) {
-	var request compat.CompatibiitySpec
-	var err error
-	cachePath := filepath.Join(cache, toFilename(uri))
-	exists, err := utils.PathExists(cachePath)
+// ParseReference parses an OCI reference (registry with optional port,
+// repository, and tag or digest). A missing tag defaults to latest.
+func ParseReference(uri string) (registry.Reference, error) {
+	ref, err := registry.ParseReference(uri)
 	if err != nil {
-		return &request, err
+		return ref, err
 	}
-	if exists {
-		fd, err := os.Open(cachePath)
-		b, err := io.ReadAll(fd)
-		if err != nil {
-			return &request, err
-		}
-		defer fd.Close()
-		err = json.Unmarshal(b, &request)
+	if ref.Reference == "" {
+		ref.Reference = "latest"
 	}
-	return &request, err
+	return ref, nil
+}
+
+// IsDigest determines if a reference is pinned to a digest
+func IsDigest(ref registry.Reference) bool {
+	return ref.ValidateReferenceAsDigest() == nil
 }
 
 // Oras will provide an interface to retrieve an artifact, specifically
 // a compatibillity spec artifact media type
 // LoadArtifact retrieves the artifact from the url string
-// and returns based on the media type
+// and returns based on the media type. The cache is keyed by the digest
+// of the manifest. A tag is resolved with the registry (a HEAD request)
+// once its cached mapping is older than the cache TTL, and the last known
+// digest is used if the registry cannot be reached. A nil cache always
+// loads from the registry. If the reference is an image index, the manifest
+// for the platform (the host if nil) is selected. With a verifier, the
+// signature is checked before the artifact is used (or cached).
 func LoadArtifact(
 	uri string,
 	mediaType string,
-	cache string,
+	platform *oci.Platform,
+	cache *Cache,
+	verifier *Verifier,
+	opts RegistryOptions,
 ) (*compat.CompatibiitySpec, error) {
 
 	request := &compat.CompatibiitySpec{}
-	var err error
-
-	// If cache is desired and we have the artifact
-	if cache != "" {
-
-		// Must exist
-		exists, err := utils.PathExists(cache)
-		if err != nil {
-			return request, err
-		}
-		if !exists {
-			return request, fmt.Errorf("Cache path %s does not exist", cache)
-		}
-		request, err := LoadFromCache(uri, cache)
+	ref, err := ParseReference(uri)
+	if err != nil {
+		return request, err
+	}
+	if platform == nil {
+		platform = HostPlatform()
+	}
+	if cache == nil {
+		request, desc, err := LoadFromRegistry(uri, mediaType, platform, opts)
 		if err != nil {
 			return request, err
 		}
+		_, err = verifyArtifact(verifier, ref, platform, desc.Digest, opts)
+		return request, err
 	}
 
-	// If we didn't get matches, load from registry
-	if request.Version == "" {
-		request, err = LoadFromRegistry(uri, mediaType)
+	// Resolve the tag (or index) to the manifest digest
+	dgst, err := resolveCached(ref, platform, cache, opts)
+	if err != nil {
+		return request, err
+	}
+	readContent, entry, err := cache.Get(dgst)
+	if err != nil {
+		return request, err
+	}
+
+	// A required signature must have been verified with the same key
+	if entry != nil && (verifier == nil || !verifier.Required || entry.Signer == verifier.Fingerprint()) {
+		return parseSpec(readContent)
+	}
+
+	// If we didn't get matches, load from registry (by digest, so
+	// we get what we resolved) and save to cache
+	signer, err := verifyArtifact(verifier, ref, platform, dgst, opts)
+	if err != nil {
+		return request, err
+	}
+	pinned := ref
+	pinned.Reference = dgst.String()
+	_, layer, readContent, err := fetchSpecLayer(pinned, mediaType, platform, opts)
+	if err != nil {
+		return request, err
+	}
+	request, err = parseSpec(readContent)
+	if err != nil {
+		return request, err
+	}
+	return request, cache.Put(dgst, layer.Digest, readContent, signer)
+}
 
-		// If we loaded it and have a cache, save to cache
-		if cache != "" {
-			err = SaveToCache(request, uri, cache)
+// verifyArtifact verifies the signature of a manifest (if there is a
+// verifier) and returns the fingerprint of the key that verified it.
+// If verification is not required, failure is a warning.
+func verifyArtifact(verifier *Verifier, ref registry.Reference, platform *oci.Platform, dgst digest.Digest, opts RegistryOptions) (string, error) {
+	if verifier == nil {
+		return "", nil
+	}
+	err := verifier.Verify(ref, platform, dgst, opts)
+	if err == nil {
+		return verifier.Fingerprint(), nil
+	}
+	if verifier.Required {
+		return "", err
+	}
+	log.Printf("warning: %s", err)
+	return "", nil
+}
+
+// resolveCached resolves a reference to a manifest digest for a platform,
+// using the cached mapping while it is within the TTL. A digest can still
+// refer to an index, but the manifest it selects never changes.
+func resolveCached(ref registry.Reference, platform *oci.Platform, cache *Cache, opts RegistryOptions) (digest.Digest, error) {
+	key := ref.String() + " " + PlatformString(platform)
+	cached, fresh, err := cache.Resolve(key)
+	if err != nil || (cached != "" && (fresh || IsDigest(ref))) {
+		return cached, err
+	}
+	desc, err := ResolveReference(ref.String(), platform, opts)
+	if err != nil {
+		if cached != "" {
+			return cached, nil
 		}
+		return "", err
 	}
-	return request, err
+	return desc.Digest, cache.Tag(key, desc.Digest)
 }
 
-// Save to cache
-func SaveToCache(request *compat.CompatibiitySpec, uri, cache string) error {
-	cachePath := filepath.Join(cache, toFilename(uri))
-	exists, err := utils.PathExists(cachePath)
+// ResolveReference resolves a reference to the descriptor of its manifest,
+// selecting the manifest for a platform if the reference is an image index
+func ResolveReference(uri string, platform *oci.Platform, opts RegistryOptions) (oci.Descriptor, error) {
+	ref, err := ParseReference(uri)
 	if err != nil {
-		return err
+		return oci.Descriptor{}, err
 	}
+	repo, err := opts.newRepository(ref)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	return resolveManifest(context.Background(), repo, ref.Reference, platform)
+}
 
-	// Don't overwrite
-	if exists {
-		return nil
+// Load the artifact from a registry, returning the spec and the
+// descriptor of the manifest it was found in (for an image index,
+// the manifest for the platform)
+func LoadFromRegistry(uri, mediaType string, platform *oci.Platform, opts RegistryOptions) (*compat.CompatibiitySpec, oci.Descriptor, error) {
+	request := compat.CompatibiitySpec{}
+	ref, err := ParseReference(uri)
+	if err != nil {
+		return &request, oci.Descriptor{}, err
 	}
-	content, err := json.Marshal(request)
+	desc, _, readContent, err := fetchSpecLayer(ref, mediaType, platform, opts)
 	if err != nil {
-		return err
+		return &request, desc, err
 	}
-	err = os.WriteFile(cachePath, content, 0644)
+	spec, err := parseSpec(readContent)
 	if err != nil {
-		return err
+		return &request, desc, fmt.Errorf("%s: %w", uri, err)
 	}
-	return nil
+	return spec, desc, nil
 }
 
-// Load the artifact from a registry
-func LoadFromRegistry(uri, mediaType string) (*compat.CompatibiitySpec, error) {
-	request := compat.CompatibiitySpec{}
+// fetchSpecLayer fetches the manifest for a reference and platform and the
+// content of its compatibility layer, returning both descriptors and the content
+func fetchSpecLayer(ref registry.Reference, mediaType string, platform *oci.Platform, opts RegistryOptions) (oci.Descriptor, oci.Descriptor, []byte, error) {
 	ctx := context.Background()
-	repo, err := remote.NewRepository(uri)
+	repo, err := opts.newRepository(ref)
 	if err != nil {
-		return &request, err
+		return oci.Descriptor{}, oci.Descriptor{}, nil, err
 	}
 
-	// Disable plain http for now, assume using production registry
-	repo.PlainHTTP = false
-
-	// Split reference into tag, or assume latest
-	tag := "latest"
-	if strings.Contains(uri, ":") {
-		parts := strings.Split(uri, ":")
-		tag = parts[1]
-		uri = parts[0]
+	// Fetch manifest for the tag or digest
+	desc, err := resolveManifest(ctx, repo, ref.Reference, platform)
+	if err != nil {
+		return desc, oci.Descriptor{}, nil, fmt.Errorf("%s: %w", ref, err)
 	}
-
-	// Fetch manifest for the tag
-	desc, readCloser, err := repo.FetchReference(ctx, tag)
+	readCloser, err := repo.Fetch(ctx, desc)
 	if err != nil {
-		return &request, err
+		return desc, oci.Descriptor{}, nil, err
 	}
 	defer readCloser.Close()
 
-	// Read the pulled content
+	// Read the pulled content (this verifies the digest)
 	manifestBytes, err := content.ReadAll(readCloser, desc)
 	if err != nil {
-		return &request, err
+		return desc, oci.Descriptor{}, nil, err
 	}
+	layer, err := findLayer(manifestBytes, mediaType)
+	if err != nil {
+		return desc, layer, nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	readContent, err := fetchLayer(ctx, repo, layer)
+	return desc, layer, readContent, err
+}
+
+// findLayer finds the compatibility layer in a manifest
+func findLayer(manifestBytes []byte, mediaType string) (oci.Descriptor, error) {
 
 	// Going to be a big wild here and not check the mnaifest media type.
 	// We'd want to find oras, but no reason it can't be pushed another way...
 	// unmarshall it
 	var manifest oci.Manifest
-	err = json.Unmarshal(manifestBytes, &manifest)
+	err := json.Unmarshal(manifestBytes, &manifest)
 	if err != nil {
-		return &request, err
+		return oci.Descriptor{}, err
 	}
 
 	// Loop through layers and find the media type we are looking for
 	for _, layer := range manifest.Layers {
-
-		// Skip layers that are not the compatibility spec... we seek
-		if layer.MediaType != mediaType {
-			continue
+		if layer.MediaType == mediaType {
+			return layer, nil
 		}
+	}
+	return oci.Descriptor{}, fmt.Errorf("manifest does not have a layer with media type %s", mediaType)
+}
 
-		// Get the descriptor for the digest we want
-		desc, err := repo.Blobs().Resolve(ctx, string(layer.Digest))
-		if err != nil {
-			return &request, err
-		}
+// parseSpec loads a spec layer. Specs can be yaml or json, and legacy
+// specs are migrated
+func parseSpec(readContent []byte) (*compat.CompatibiitySpec, error) {
+	readContent, err := yaml.YAMLToJSON(readContent)
+	if err != nil {
+		return &compat.CompatibiitySpec{}, err
+	}
+	return compat.Load(readContent)
+}
 
-		// Download using the descriptor
-		readCloser, err := repo.Fetch(ctx, desc)
-		if err != nil {
-			return &request, err
-		}
-		defer readCloser.Close()
+// fetchLayer downloads a layer and verifies its digest
+func fetchLayer(ctx context.Context, repo *remote.Repository, layer oci.Descriptor) ([]byte, error) {
 
-		// Read the descriptor into bytes
-		vr := content.NewVerifyReader(readCloser, desc)
-		buffer := bytes.NewBuffer(nil)
-		_, err = io.Copy(buffer, vr)
-		if err != nil {
-			return &request, err
-		}
+	// Get the descriptor for the digest we want
+	desc, err := repo.Blobs().Resolve(ctx, string(layer.Digest))
+	if err != nil {
+		return nil, err
+	}
 
-		// note: users should not trust the the read content until Verify returns nil
-		if err := vr.Verify(); err != nil {
-			return &request, err
-		}
+	// Download using the descriptor
+	readCloser, err := repo.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
 
-		// Convert this into our Compatibility Request
-		// reading from the buffer into bytes proper
-		readContent, err := io.ReadAll(buffer)
-		if err != nil {
-			return &request, err
-		}
+	// Read the descriptor into bytes
+	vr := content.NewVerifyReader(readCloser, desc)
+	buffer := bytes.NewBuffer(nil)
+	_, err = io.Copy(buffer, vr)
+	if err != nil {
+		return nil, err
+	}
 
-		err = yaml.Unmarshal(readContent, &request)
-		if err != nil {
-			return &request, err
-		}
+	// note: users should not trust the the read content until Verify returns nil
+	if err := vr.Verify(); err != nil {
+		return nil, err
 	}
-	return &request, nil
+	return buffer.Bytes(), nil
 }
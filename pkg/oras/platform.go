@@ -0,0 +1,64 @@
+package oras
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Architectures (GOARCH) for machine names in a compatibility spec
+var machineArchitectures = map[string]string{
+	"x86_64":  "amd64",
+	"i386":    "386",
+	"aarch64": "arm64",
+	"arm":     "arm",
+	"ppc64le": "ppc64le",
+	"ppc64":   "ppc64",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// ParsePlatform parses a platform of the form os/arch[/variant]
+// An empty string is the platform of the host.
+func ParsePlatform(value string) (*oci.Platform, error) {
+	if value == "" {
+		return HostPlatform(), nil
+	}
+	parts := strings.Split(value, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("platform %s must be of the form os/arch[/variant]", value)
+	}
+	platform := oci.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return &platform, nil
+}
+
+// PlatformString returns the os/arch[/variant] form of a platform
+func PlatformString(platform *oci.Platform) string {
+	value := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		value += "/" + platform.Variant
+	}
+	return value
+}
+
+// SpecPlatform derives the platform of a spec from its hardware
+// and the OS/ABI of the executable
+func SpecPlatform(spec *compat.CompatibiitySpec) (*oci.Platform, error) {
+	arch, ok := machineArchitectures[spec.Hardware.Machine]
+	if !ok {
+		return nil, fmt.Errorf("no platform architecture for machine %q", spec.Hardware.Machine)
+	}
+
+	// Most toolchains leave the OS/ABI as System V (none) on Linux
+	os := spec.Executable.OSABI
+	switch os {
+	case "", "none", "linux", "gnu":
+		os = "linux"
+	}
+	return &oci.Platform{OS: os, Architecture: arch}, nil
+}
@@ -0,0 +1,198 @@
+package oras
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+	specs_go "github.com/opencontainers/image-spec/specs-go"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	orasgo "oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+const (
+	// Artifact type for the manifest of a compatibility artifact
+	CompatibilityArtifactType = "application/vnd.llnl.compatlib.artifact.v1"
+
+	// Title (filename) for the spec layer
+	CompatibilityTitle = "compatibility-spec.json"
+
+	// Annotations for the manifest
+	AnnotationExecutable  = "llnl.compatlib.executable-name"
+	AnnotationSpecVersion = "llnl.compatlib.spec-version"
+)
+
+// PushArtifact pushes a compatibility spec to a registry as a layer with
+// the compatibility media type. If a subject (an image reference in the same
+// repository) is provided, the artifact is attached to it as a referrer.
+func PushArtifact(
+	spec *compat.CompatibiitySpec,
+	uri string,
+	mediaType string,
+	subject string,
+	opts RegistryOptions,
+) (oci.Descriptor, error) {
+
+	ctx := context.Background()
+	ref, err := pushReference(uri)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	repo, err := opts.newRepository(ref)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+
+	// The subject must be in the same repository to be found as a referrer
+	var subjectDesc *oci.Descriptor
+	if subject != "" {
+		desc, err := resolveSubject(ctx, repo, ref, subject)
+		if err != nil {
+			return oci.Descriptor{}, err
+		}
+		subjectDesc = &desc
+	}
+	desc, err := pushManifest(ctx, repo, spec, mediaType, subjectDesc)
+	if err != nil {
+		return desc, err
+	}
+	return desc, tagReference(ctx, repo, ref, desc)
+}
+
+// PushIndex pushes a compatibility spec for each platform, and an image
+// index that refers to them. The platform of each spec is derived from its
+// hardware, so a client selects the spec for its own platform. If a subject
+// is provided, the index is attached to it as a referrer.
+func PushIndex(
+	specs []*compat.CompatibiitySpec,
+	uri string,
+	mediaType string,
+	subject string,
+	opts RegistryOptions,
+) (oci.Descriptor, error) {
+
+	ctx := context.Background()
+	ref, err := pushReference(uri)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	repo, err := opts.newRepository(ref)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	index := oci.Index{
+		Versioned:    specs_go.Versioned{SchemaVersion: 2},
+		MediaType:    oci.MediaTypeImageIndex,
+		ArtifactType: CompatibilityArtifactType,
+		Manifests:    []oci.Descriptor{},
+	}
+
+	// Push a manifest for each platform
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		platform, err := SpecPlatform(spec)
+		if err != nil {
+			return oci.Descriptor{}, fmt.Errorf("%s: %w", spec.Executable.Name, err)
+		}
+		if seen[PlatformString(platform)] {
+			return oci.Descriptor{}, fmt.Errorf("more than one spec for platform %s", PlatformString(platform))
+		}
+		seen[PlatformString(platform)] = true
+		desc, err := pushManifest(ctx, repo, spec, mediaType, nil)
+		if err != nil {
+			return oci.Descriptor{}, err
+		}
+		desc.Platform = platform
+		desc.ArtifactType = CompatibilityArtifactType
+		index.Manifests = append(index.Manifests, desc)
+	}
+
+	if subject != "" {
+		subjectDesc, err := resolveSubject(ctx, repo, ref, subject)
+		if err != nil {
+			return oci.Descriptor{}, err
+		}
+		index.Subject = &subjectDesc
+	}
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	desc := content.NewDescriptorFromBytes(oci.MediaTypeImageIndex, indexBytes)
+	desc.ArtifactType = CompatibilityArtifactType
+	err = repo.Push(ctx, desc, bytes.NewReader(indexBytes))
+	if err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return desc, err
+	}
+	return desc, tagReference(ctx, repo, ref, desc)
+}
+
+// pushManifest pushes a spec as the only layer of a manifest
+func pushManifest(
+	ctx context.Context,
+	repo *remote.Repository,
+	spec *compat.CompatibiitySpec,
+	mediaType string,
+	subject *oci.Descriptor,
+) (oci.Descriptor, error) {
+
+	specBytes, err := spec.ToJson()
+	if err != nil {
+		return oci.Descriptor{}, err
+	}
+	layer := content.NewDescriptorFromBytes(mediaType, specBytes)
+	err = repo.Push(ctx, layer, bytes.NewReader(specBytes))
+	if err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
+		return oci.Descriptor{}, err
+	}
+	layer.Annotations = map[string]string{oci.AnnotationTitle: CompatibilityTitle}
+
+	packOpts := orasgo.PackManifestOptions{
+		Layers:  []oci.Descriptor{layer},
+		Subject: subject,
+		ManifestAnnotations: map[string]string{
+			AnnotationExecutable:  spec.Executable.Name,
+			AnnotationSpecVersion: spec.Version,
+		},
+	}
+	return orasgo.PackManifest(ctx, repo, orasgo.PackManifestVersion1_1, CompatibilityArtifactType, packOpts)
+}
+
+// pushReference parses a reference to push to, which must be a tag (a
+// digest is only known once the content is pushed)
+func pushReference(uri string) (registry.Reference, error) {
+	ref, err := ParseReference(uri)
+	if err != nil {
+		return ref, err
+	}
+	if IsDigest(ref) {
+		return ref, fmt.Errorf("cannot push to %s, which is a digest (use a tag)", uri)
+	}
+	return ref, nil
+}
+
+// tagReference tags a pushed descriptor
+func tagReference(ctx context.Context, repo *remote.Repository, ref registry.Reference, desc oci.Descriptor) error {
+	return repo.Tag(ctx, desc, ref.Reference)
+}
+
+// resolveSubject resolves the descriptor of a subject image. The subject
+// can be a full reference or a tag or digest in the same repository.
+func resolveSubject(ctx context.Context, repo *remote.Repository, ref registry.Reference, subject string) (oci.Descriptor, error) {
+	subjectRef, err := registry.ParseReference(subject)
+	if err != nil {
+		subjectRef = ref
+		subjectRef.Reference = subject
+	}
+	if subjectRef.Registry != ref.Registry || subjectRef.Repository != ref.Repository {
+		return oci.Descriptor{}, fmt.Errorf("subject %s must be in the same repository as %s", subject, ref.Registry+"/"+ref.Repository)
+	}
+	return repo.Resolve(ctx, subjectRef.Reference)
+}
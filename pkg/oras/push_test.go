@@ -0,0 +1,74 @@
+package oras
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+)
+
+// xzSpec returns a spec to push
+func xzSpec() *compat.CompatibiitySpec {
+	spec := compat.NewCompatibilitySpec()
+	spec.Executable.Name = "xz"
+	spec.AddLibrary("libc.so.6")
+	return spec
+}
+
+// The test registry serves plain http
+var plainHTTP = RegistryOptions{PlainHTTP: true}
+
+func TestPushArtifact(t *testing.T) {
+	r := newTestRegistry(t)
+
+	// A reference without a tag is tagged latest
+	for uri, tag := range map[string]string{
+		r.ref("compat"):       "compat",
+		r.host + "/test/spec": "latest",
+	} {
+		desc, err := PushArtifact(xzSpec(), uri, CompatibilityMediaType, "", plainHTTP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tagged, manifest := r.manifest(t, tag)
+		if tagged.Digest != desc.Digest {
+			t.Errorf("PushArtifact(%s) tagged %s as %s, want %s", uri, tag, tagged.Digest, desc.Digest)
+		}
+		if len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != CompatibilityMediaType {
+			t.Errorf("PushArtifact(%s) layers = %+v, want one spec layer", uri, manifest.Layers)
+		}
+		if manifest.Annotations[AnnotationExecutable] != "xz" {
+			t.Errorf("PushArtifact(%s) annotations = %v, want executable xz", uri, manifest.Annotations)
+		}
+	}
+}
+
+func TestPushArtifactSubject(t *testing.T) {
+	r := newTestRegistry(t)
+	image := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "a"}}`, "image")
+
+	desc, err := PushArtifact(xzSpec(), r.ref("compat"), CompatibilityMediaType, "image", plainHTTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, manifest := r.manifest(t, desc.Digest.String())
+	if manifest.Subject == nil || manifest.Subject.Digest != image.Digest {
+		t.Errorf("PushArtifact() subject = %+v, want %s", manifest.Subject, image.Digest)
+	}
+
+	// A referrer is only found in the repository of its subject
+	_, err = PushArtifact(xzSpec(), r.ref("compat"), CompatibilityMediaType, "ghcr.io/test/spec:image", plainHTTP)
+	if err == nil || !strings.Contains(err.Error(), "same repository") {
+		t.Errorf("PushArtifact() error = %v for a subject in another repository", err)
+	}
+}
+
+// A digest is only known once the content is pushed, so it cannot be a target
+func TestPushArtifactDigest(t *testing.T) {
+	r := newTestRegistry(t)
+	existing := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "a"}}`, "")
+	_, err := PushArtifact(xzSpec(), r.ref(existing.Digest.String()), CompatibilityMediaType, "", plainHTTP)
+	if err == nil || !strings.Contains(err.Error(), "is a digest") {
+		t.Errorf("PushArtifact() error = %v, want a refused digest", err)
+	}
+}
@@ -0,0 +1,83 @@
+package oras
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/credentials"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// RegistryOptions configure how we connect to a registry
+type RegistryOptions struct {
+
+	// Use plain http (e.g., for a local registry:2 in CI)
+	PlainHTTP bool
+
+	// Path to a docker config.json for credentials (and credential
+	// helpers). If unset, the default docker config is used if found.
+	ConfigPath string
+
+	// PEM bundle of certificate authorities to trust (in addition to the system)
+	CAFile string
+}
+
+// newRepository returns a remote repository for a reference, with
+// credentials and transport configured by the options
+func (o RegistryOptions) newRepository(ref registry.Reference) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(ref.Registry + "/" + ref.Repository)
+	if err != nil {
+		return nil, err
+	}
+	repo.PlainHTTP = o.PlainHTTP
+
+	client, err := o.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	authClient := &auth.Client{
+		Client: client,
+		Cache:  auth.NewCache(),
+	}
+
+	storeOptions := credentials.StoreOptions{DetectDefaultNativeStore: true}
+	var store *credentials.DynamicStore
+	if o.ConfigPath != "" {
+		store, err = credentials.NewStore(o.ConfigPath, storeOptions)
+	} else {
+		store, err = credentials.NewStoreFromDocker(storeOptions)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot load registry credentials: %w", err)
+	}
+	authClient.Credential = credentials.Credential(store)
+	repo.Client = authClient
+	return repo, nil
+}
+
+// httpClient returns an http client that trusts the custom CA bundle
+func (o RegistryOptions) httpClient() (*http.Client, error) {
+	if o.CAFile == "" {
+		return retry.DefaultClient, nil
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	pem, err := os.ReadFile(o.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", o.CAFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return &http.Client{Transport: retry.NewTransport(transport)}, nil
+}
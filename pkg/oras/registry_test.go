@@ -0,0 +1,181 @@
+package oras
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	specs_go "github.com/opencontainers/image-spec/specs-go"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// testRegistry is an in memory registry with the parts of the distribution
+// API that pushing, pulling and verifying use (without referrers, so
+// signatures are found by their tag)
+type testRegistry struct {
+	mutex     sync.Mutex
+	blobs     map[digest.Digest][]byte
+	manifests map[digest.Digest]oci.Descriptor
+	tags      map[string]digest.Digest
+	host      string
+}
+
+// newTestRegistry starts a registry that stops when the test ends
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[digest.Digest]oci.Descriptor{},
+		tags:      map[string]digest.Digest{},
+	}
+	server := httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(server.Close)
+	r.host = strings.TrimPrefix(server.URL, "http://")
+	return r
+}
+
+// ref returns a reference to the test repository
+func (r *testRegistry) ref(reference string) string {
+	separator := ":"
+	if strings.HasPrefix(reference, "sha256:") {
+		separator = "@"
+	}
+	return r.host + "/test/spec" + separator + reference
+}
+
+// addBlob stores content and returns its descriptor
+func (r *testRegistry) addBlob(mediaType string, data []byte) oci.Descriptor {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	dgst := digest.FromBytes(data)
+	r.blobs[dgst] = data
+	return oci.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+}
+
+// addManifest stores a manifest (or index), tagged if the tag is set
+func (r *testRegistry) addManifest(t *testing.T, manifest any, mediaType, tag string) oci.Descriptor {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := r.addBlob(mediaType, data)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.manifests[desc.Digest] = desc
+	if tag != "" {
+		r.tags[tag] = desc.Digest
+	}
+	return desc
+}
+
+// addSpec stores a manifest with a spec layer
+func (r *testRegistry) addSpec(t *testing.T, spec, tag string) oci.Descriptor {
+	config := r.addBlob(oci.MediaTypeEmptyJSON, []byte("{}"))
+	layer := r.addBlob(CompatibilityMediaType, []byte(spec))
+	manifest := oci.Manifest{
+		Versioned: specs_go.Versioned{SchemaVersion: 2},
+		MediaType: oci.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []oci.Descriptor{layer},
+	}
+	return r.addManifest(t, manifest, oci.MediaTypeImageManifest, tag)
+}
+
+// manifest returns a stored manifest by its tag or digest
+func (r *testRegistry) manifest(t *testing.T, reference string) (oci.Descriptor, oci.Manifest) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	dgst, ok := r.tags[reference]
+	if !ok {
+		dgst = digest.Digest(reference)
+	}
+	manifest := oci.Manifest{}
+	desc, ok := r.manifests[dgst]
+	if !ok {
+		t.Fatalf("no manifest %s in the registry", reference)
+	}
+	err := json.Unmarshal(r.blobs[dgst], &manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return desc, manifest
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	path := req.URL.Path
+	switch {
+	case path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+
+	case strings.Contains(path, "/blobs/uploads/"):
+		if req.Method == http.MethodPost {
+			w.Header().Set("Location", path+"upload")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := io.ReadAll(req.Body)
+		dgst := digest.Digest(req.URL.Query().Get("digest"))
+		if digest.FromBytes(data) != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[dgst] = data
+		w.Header().Set("Location", strings.Replace(path, "/uploads/upload", "/"+dgst.String(), 1))
+		w.WriteHeader(http.StatusCreated)
+
+	case strings.Contains(path, "/blobs/"):
+		dgst := digest.Digest(path[strings.LastIndex(path, "/")+1:])
+		r.write(w, req, "application/octet-stream", dgst)
+
+	case strings.Contains(path, "/manifests/"):
+		reference := path[strings.LastIndex(path, "/")+1:]
+		if req.Method == http.MethodPut {
+			data, _ := io.ReadAll(req.Body)
+			dgst := digest.FromBytes(data)
+			r.blobs[dgst] = data
+			r.manifests[dgst] = oci.Descriptor{MediaType: req.Header.Get("Content-Type"), Digest: dgst, Size: int64(len(data))}
+			if !strings.HasPrefix(reference, "sha256:") {
+				r.tags[reference] = dgst
+			}
+			w.Header().Set("Docker-Content-Digest", dgst.String())
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		dgst, ok := r.tags[reference]
+		if !ok {
+			dgst = digest.Digest(reference)
+		}
+		desc, ok := r.manifests[dgst]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.write(w, req, desc.MediaType, dgst)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// write writes content (or only its headers, for HEAD)
+func (r *testRegistry) write(w http.ResponseWriter, req *http.Request, mediaType string, dgst digest.Digest) {
+	data, ok := r.blobs[dgst]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if req.Method != http.MethodHead {
+		io.Copy(w, bytes.NewReader(data))
+	}
+}
@@ -0,0 +1,233 @@
+package oras
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/registry"
+	"oras.land/oras-go/v2/registry/remote"
+)
+
+const (
+	// Media type of a cosign signature payload (simple signing)
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// Annotation on the payload layer with the base64 signature
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// Type in the critical section of a cosign payload
+	cosignSignatureType = "cosign container image signature"
+)
+
+// ErrUnverified is returned when an artifact has no valid signature
+var ErrUnverified = errors.New("artifact signature cannot be verified")
+
+// A Verifier checks cosign-style keyed signatures of artifacts against a
+// public key. Signatures are found as referrers of the manifest, or with
+// the cosign tag (sha256-<hex>.sig), and no transparency log is used.
+type Verifier struct {
+
+	// Refuse artifacts that cannot be verified (otherwise warn)
+	Required bool
+
+	key         crypto.PublicKey
+	fingerprint string
+}
+
+// simpleSigning is the payload of a cosign signature
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// NewVerifier loads a PEM encoded (PKIX) ECDSA, ed25519, or RSA public key
+func NewVerifier(keyPath string, required bool) (*Verifier, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not have a PEM encoded public key", keyPath)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("%s: unsupported public key type %T", keyPath, key)
+	}
+	return &Verifier{
+		Required:    required,
+		key:         key,
+		fingerprint: digest.FromBytes(block.Bytes).String(),
+	}, nil
+}
+
+// Fingerprint is the digest of the public key
+func (v *Verifier) Fingerprint() string {
+	return v.fingerprint
+}
+
+// Verify checks that a manifest is signed by the key, or that the
+// reference (as pushed, e.g., a tag of an index) resolves to an index that
+// selects the manifest for the platform and is signed. A signed manifest is
+// verified by its digest, so it does not matter where a tag points now
+// (e.g., a cached digest for a tag that has since moved).
+func (v *Verifier) Verify(ref registry.Reference, platform *oci.Platform, manifest digest.Digest, opts RegistryOptions) error {
+	ctx := context.Background()
+	repo, err := opts.newRepository(ref)
+	if err != nil {
+		return err
+	}
+	desc, err := repo.Resolve(ctx, manifest.String())
+	if err != nil {
+		return err
+	}
+	err = v.verifyDescriptor(ctx, repo, desc)
+	if err == nil {
+		return nil
+	}
+	problems := []error{err}
+
+	// A signature of an index covers the manifests it refers to
+	root, err := repo.Resolve(ctx, ref.Reference)
+	switch {
+	case err != nil:
+		problems = append(problems, err)
+	case root.Digest == manifest || !isIndex(root.MediaType):
+	default:
+		selected, err := resolveManifest(ctx, repo, root.Digest.String(), platform)
+		if err != nil {
+			problems = append(problems, err)
+			break
+		}
+		if selected.Digest != manifest {
+			problems = append(problems, fmt.Errorf("index %s no longer selects %s", root.Digest, manifest))
+			break
+		}
+		err = v.verifyDescriptor(ctx, repo, root)
+		if err == nil {
+			return nil
+		}
+		problems = append(problems, err)
+	}
+	return fmt.Errorf("%w: %s: %w", ErrUnverified, ref, errors.Join(problems...))
+}
+
+// verifyDescriptor looks for a valid signature of a manifest, first in
+// referrers and then with the cosign signature tag
+func (v *Verifier) verifyDescriptor(ctx context.Context, repo *remote.Repository, desc oci.Descriptor) error {
+	signatures := []oci.Descriptor{}
+	err := repo.Referrers(ctx, desc, "", func(referrers []oci.Descriptor) error {
+		signatures = append(signatures, referrers...)
+		return nil
+	})
+
+	// Not all registries support referrers, so failure falls back to the tag
+	if err != nil {
+		signatures = []oci.Descriptor{}
+	}
+	tag := strings.Replace(desc.Digest.String(), ":", "-", 1) + ".sig"
+	tagged, err := repo.Resolve(ctx, tag)
+	if err == nil {
+		signatures = append(signatures, tagged)
+	}
+	if len(signatures) == 0 {
+		return fmt.Errorf("no signatures found for %s", desc.Digest)
+	}
+
+	for _, signature := range signatures {
+		if v.verifyManifest(ctx, repo, signature, desc.Digest) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no valid signature for %s (%d checked)", desc.Digest, len(signatures))
+}
+
+// verifyManifest checks the simple signing layers in a signature manifest
+func (v *Verifier) verifyManifest(ctx context.Context, repo *remote.Repository, signature oci.Descriptor, dgst digest.Digest) error {
+	manifestBytes, err := content.FetchAll(ctx, repo, signature)
+	if err != nil {
+		return err
+	}
+	var manifest oci.Manifest
+	err = json.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		return err
+	}
+	for _, layer := range manifest.Layers {
+		encoded, ok := layer.Annotations[SignatureAnnotation]
+		if layer.MediaType != SimpleSigningMediaType || !ok {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			continue
+		}
+		payload, err := fetchLayer(ctx, repo, layer)
+		if err != nil {
+			continue
+		}
+		if v.verifyPayload(payload, sig, dgst) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature manifest %s has no valid signature", signature.Digest)
+}
+
+// verifyPayload checks a signature of a simple signing payload, and that
+// the payload is for the digest
+func (v *Verifier) verifyPayload(payload, sig []byte, dgst digest.Digest) error {
+	hash := sha256.Sum256(payload)
+	valid := false
+	switch key := v.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, hash[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, payload, sig)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil
+	}
+	if !valid {
+		return errors.New("invalid signature")
+	}
+
+	// Only trust the payload after the signature is checked
+	var signed simpleSigning
+	err := json.Unmarshal(payload, &signed)
+	if err != nil {
+		return err
+	}
+	if signed.Critical.Type != cosignSignatureType {
+		return fmt.Errorf("unexpected signature type %q", signed.Critical.Type)
+	}
+	if signed.Critical.Image.DockerManifestDigest != dgst.String() {
+		return fmt.Errorf("signature is for %s, not %s", signed.Critical.Image.DockerManifestDigest, dgst)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package oras
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	specs_go "github.com/opencontainers/image-spec/specs-go"
+	oci "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// newTestVerifier returns a verifier for a new ed25519 key, and the key
+func newTestVerifier(t *testing.T) (*Verifier, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cosign.pub")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifier, private
+}
+
+// sign pushes a cosign signature of a manifest with the signature tag
+func (r *testRegistry) sign(t *testing.T, key ed25519.PrivateKey, desc oci.Descriptor) {
+	var payload simpleSigning
+	payload.Critical.Type = cosignSignatureType
+	payload.Critical.Image.DockerManifestDigest = desc.Digest.String()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer := r.addBlob(SimpleSigningMediaType, data)
+	layer.Annotations = map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))}
+	manifest := oci.Manifest{
+		Versioned: specs_go.Versioned{SchemaVersion: 2},
+		MediaType: oci.MediaTypeImageManifest,
+		Config:    r.addBlob(oci.MediaTypeEmptyJSON, []byte("{}")),
+		Layers:    []oci.Descriptor{layer},
+	}
+	r.addManifest(t, manifest, oci.MediaTypeImageManifest, strings.Replace(desc.Digest.String(), ":", "-", 1)+".sig")
+}
+
+func TestVerify(t *testing.T) {
+	r := newTestRegistry(t)
+	verifier, key := newTestVerifier(t)
+	platform := &oci.Platform{OS: "linux", Architecture: "amd64"}
+
+	// A signed manifest whose tag moved to another (unsigned) manifest
+	signed := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "a"}}`, "moved")
+	r.sign(t, key, signed)
+	unsigned := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "b"}}`, "moved")
+
+	// An index is signed for the manifests it selects
+	index := func(tag string, manifest oci.Descriptor) {
+		manifest.Platform = platform
+		desc := r.addManifest(t, oci.Index{
+			Versioned: specs_go.Versioned{SchemaVersion: 2},
+			MediaType: oci.MediaTypeImageIndex,
+			Manifests: []oci.Descriptor{manifest},
+		}, oci.MediaTypeImageIndex, tag)
+		r.sign(t, key, desc)
+	}
+	selected := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "c"}}`, "")
+	other := r.addSpec(t, `{"version": "0.1.0", "executable": {"name": "d"}}`, "")
+	index("index", selected)
+	index("other", other)
+
+	tests := []struct {
+		name     string
+		ref      string
+		manifest oci.Descriptor
+		verified bool
+	}{
+		{"signed manifest after its tag moved", "moved", signed, true},
+		{"unsigned manifest", "moved", unsigned, false},
+		{"signed manifest by digest", signed.Digest.String(), signed, true},
+		{"manifest selected by a signed index", "index", selected, true},
+		{"manifest not selected by a signed index", "other", selected, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ref, err := ParseReference(r.ref(test.ref))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = verifier.Verify(ref, platform, test.manifest.Digest, RegistryOptions{PlainHTTP: true})
+			if test.verified && err != nil {
+				t.Errorf("Verify() = %v, want verified", err)
+			}
+			if !test.verified && !errors.Is(err, ErrUnverified) {
+				t.Errorf("Verify() = %v, want %v", err, ErrUnverified)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/compspec/compat-lib/pkg/utils"
+)
+
+// Runners execute a command with a fuse mount as its root
+const (
+
+	// Try unshare, and fall back to proot if namespaces are not allowed
+	Auto = "auto"
+
+	// Unprivileged user and mount namespaces, and chroot into the mount
+	Unshare = "unshare"
+
+	// proot, which intercepts every syscall with ptrace
+	Proot = "proot"
+)
+
+// Runners that can be selected
+var Runners = []string{Auto, Unshare, Proot}
+
+const (
+
+	// Set for the process that prepares the root in the new namespaces
+	rootEnv = "COMPATLIB_RUNNER_ROOT"
+
+	// The running executable, re-executed to prepare the root
+	self = "/proc/self/exe"
+
+	// File descriptor for errors preparing the root, closed on exec
+	statusFd = 3
+)
+
+// Kernel filesystems that cannot be served through the loopback mount,
+// and are bound from the host (as proot -S does)
+var hostMounts = []string{"/proc", "/dev", "/sys"}
+
+// Init must be called at the start of main. In the process that the
+// unshare runner starts, it prepares the root and replaces itself with
+// the command, and never returns. Otherwise it does nothing.
+func Init() {
+	root := os.Getenv(rootEnv)
+	if root == "" {
+		return
+	}
+	os.Unsetenv(rootEnv)
+	syscall.CloseOnExec(statusFd)
+	status := os.NewFile(statusFd, "status")
+	err := enterRoot(root)
+	if err == nil {
+		err = syscall.Exec(os.Args[1], os.Args[1:], os.Environ())
+	}
+	fmt.Fprintf(status, "cannot run %s in %s: %s", strings.Join(os.Args[1:], " "), root, err)
+	os.Exit(127)
+}
+
+// Run runs a command (args[0] is a full path) with root as its filesystem
+// root, and returns the runner that was used
+func Run(runner, root string, args []string) (string, error) {
+	switch runner {
+	case Proot:
+		return Proot, runProot(root, args)
+	case Unshare:
+		cmd, err := startUnshare(root, args)
+		if err != nil {
+			return Unshare, err
+		}
+		return Unshare, cmd.Wait()
+	case Auto, "":
+		cmd, err := startUnshare(root, args)
+		if err == nil {
+			return Unshare, cmd.Wait()
+		}
+		fmt.Printf("%s, falling back to proot\n", err)
+		return Proot, runProot(root, args)
+	}
+	return "", fmt.Errorf("unknown runner %q, choices are %s", runner, strings.Join(Runners, ", "))
+}
+
+// ExitCode returns the exit code of a command from the error of running
+// it (-1 if it did not run)
+func ExitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+// startUnshare re-executes this program in new user and mount namespaces,
+// mapped to root (like proot -0), to prepare the root and run the command.
+// It returns once the command is started, or the root could not be prepared.
+func startUnshare(root string, args []string) (*exec.Cmd, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	cmd := newCommand(self, args...)
+	cmd.Env = append(os.Environ(), rootEnv+"="+root)
+	cmd.ExtraFiles = []*os.File{writer}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+	}
+	err = cmd.Start()
+	writer.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cannot create user and mount namespaces: %w", err)
+	}
+
+	// The status is closed without a message when the command starts
+	status, _ := io.ReadAll(reader)
+	if len(status) > 0 {
+		cmd.Wait()
+		return nil, errors.New(string(status))
+	}
+	return cmd, nil
+}
+
+// enterRoot binds the host kernel filesystems into the root, changes
+// root, and returns to the working directory under it. Mounts are
+// private to the new mount namespace.
+func enterRoot(root string) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	err = syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+	if err != nil {
+		return fmt.Errorf("cannot make mounts private: %w", err)
+	}
+	for _, path := range hostMounts {
+		target := filepath.Join(root, path)
+		if _, err := os.Stat(target); err != nil {
+			continue
+		}
+		err = syscall.Mount(path, target, "", syscall.MS_BIND|syscall.MS_REC, "")
+		if err != nil {
+			return fmt.Errorf("cannot bind %s: %w", path, err)
+		}
+	}
+	err = syscall.Chroot(root)
+	if err != nil {
+		return fmt.Errorf("cannot change root: %w", err)
+	}
+	if err := syscall.Chdir(cwd); err != nil {
+		return syscall.Chdir("/")
+	}
+	return nil
+}
+
+// runProot runs a command with proot, with the host kernel filesystems
+// bound (-S) and as a fake root user (-0)
+func runProot(root string, args []string) error {
+	proot, err := utils.FullPath("proot")
+	if err != nil {
+		return fmt.Errorf("cannot find proot executable: %w", err)
+	}
+	args = append([]string{"-S", root, "-0"}, args...)
+	fmt.Println(proot + " " + strings.Join(args, " "))
+	return newCommand(proot, args...).Run()
+}
+
+// newCommand returns a command using the standard outputs, run from the
+// current working directory
+func newCommand(path string, args ...string) *exec.Cmd {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+	"github.com/compspec/compat-lib/pkg/inventory"
+	pb "github.com/compspec/compat-lib/protos"
+)
+
+// OS/ABI values (from the ELF header) that run on Linux
+var supportedOSABI = map[string]bool{"": true, "none": true, "linux": true}
+
+// libraries resolves the libraries of a spec on the host (the inventory)
+type libraries interface {
+	Lookup(soname, machine, class string) []string
+	Defines(path, version string) bool
+}
+
+// checkSpec evaluates a compatibility spec against the host
+func (s *Server) checkSpec(spec *compat.CompatibiitySpec) (*pb.Response, error) {
+	result := compat.NewCompatibilityResult()
+
+	// If the binary cannot run here, we don't need to look for libraries
+	result.Incompatible = checkHost(spec, s.inventory.Host)
+	if len(result.Incompatible) > 0 {
+		return newResponse(result)
+	}
+	checkLibraries(spec, s.inventory, result)
+	result.Compatible = len(result.Missing) == 0 && len(result.MissingVersions) == 0
+	return newResponse(result)
+}
+
+// checkLibraries finds the libraries of a spec on the host, and the symbol
+// versions they do not define
+func checkLibraries(spec *compat.CompatibiitySpec, libs libraries, result *compat.CompatibilityResult) {
+
+	// Only libraries for the machine and class of the binary can be loaded
+	machine, class := spec.Hardware.Machine, spec.Hardware.Class
+	for _, soname := range spec.Sonames() {
+		paths := libs.Lookup(soname, machine, class)
+		if len(paths) == 0 {
+			result.Missing = append(result.Missing, soname)
+			continue
+		}
+		result.Found[soname] = paths
+	}
+
+	// A library must define the needed versions. The first candidate that
+	// defines them all is put first, since one earlier in the search order
+	// can be passed over (e.g., with LD_LIBRARY_PATH). Without one, the
+	// versions are missing from the library the linker loads (the first).
+	for soname, versions := range spec.VersionNeeds() {
+		paths, ok := result.Found[soname]
+		if !ok {
+			continue
+		}
+		idx := slices.IndexFunc(paths, func(path string) bool {
+			for _, version := range versions {
+				if !libs.Defines(path, version) {
+					return false
+				}
+			}
+			return true
+		})
+		if idx > 0 {
+			result.Found[soname] = append([]string{paths[idx]}, slices.Delete(slices.Clone(paths), idx, idx+1)...)
+		}
+		if idx >= 0 {
+			continue
+		}
+		for _, version := range versions {
+			if !libs.Defines(paths[0], version) {
+				result.MissingVersions[soname] = append(result.MissingVersions[soname], version)
+			}
+		}
+	}
+}
+
+// checkHost checks properties of the binary (ISA, ABI, OS) against the host
+// and returns the reasons it cannot run here.
+func checkHost(spec *compat.CompatibiitySpec, host *inventory.Host) []string {
+	reasons := []string{}
+	machine, class := spec.Hardware.Machine, spec.Hardware.Class
+	if !host.SupportsMachine(machine, class) {
+		reasons = append(reasons, fmt.Sprintf("machine %s (%s) is not supported by host %s (%s)", machine, class, host.Machine, host.Class))
+	}
+	endianness := spec.Hardware.Endianness
+	if endianness != "" && endianness != host.Endianness {
+		reasons = append(reasons, fmt.Sprintf("endianness %s does not match host %s", endianness, host.Endianness))
+	}
+	if osabi := spec.Executable.OSABI; !supportedOSABI[osabi] {
+		reasons = append(reasons, fmt.Sprintf("OS/ABI %s is not supported on Linux", osabi))
+	}
+	if interpreter := spec.Executable.Interpreter; interpreter != "" {
+		if _, err := os.Stat(interpreter); err != nil {
+			reasons = append(reasons, fmt.Sprintf("interpreter %s does not exist on the host", interpreter))
+		}
+	}
+	minKernel := spec.Executable.MinKernel
+	if minKernel != "" && !host.SupportsKernel(minKernel) {
+		reasons = append(reasons, fmt.Sprintf("kernel %s is older than required %s", host.Kernel, minKernel))
+	}
+	return reasons
+}
+
+// newResponse wraps a result in a response
+func newResponse(result *compat.CompatibilityResult) (*pb.Response, error) {
+	payload, err := result.ToJson()
+	if err != nil {
+		return nil, err
+	}
+	response := &pb.Response{
+		Payload:    string(payload),
+		Compatible: result.Compatible,
+		Status:     pb.Response_SUCCESS,
+	}
+	if !result.Compatible {
+		response.Status = pb.Response_DENIED
+	}
+	return response, nil
+}
+
+// errorResponse returns a response for a request that cannot be checked
+func errorResponse(message string) (*pb.Response, error) {
+	result := compat.NewCompatibilityResult()
+	result.Message = message
+	payload, err := result.ToJson()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Response{Payload: string(payload), Status: pb.Response_ERROR}, nil
+}
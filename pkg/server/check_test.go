@@ -0,0 +1,159 @@
+package server
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/compat"
+	"github.com/compspec/compat-lib/pkg/inventory"
+)
+
+// hostLibrary is a library path on a test host and the versions it defines
+type hostLibrary struct {
+	path     string
+	versions []string
+}
+
+// hostLibraries are the candidates for each soname, in search order
+type hostLibraries map[string][]hostLibrary
+
+func (h hostLibraries) Lookup(soname, machine, class string) []string {
+	paths := []string{}
+	for _, library := range h[soname] {
+		paths = append(paths, library.path)
+	}
+	return paths
+}
+
+func (h hostLibraries) Defines(path, version string) bool {
+	for _, libraries := range h {
+		for _, library := range libraries {
+			if library.path == path {
+				return slices.Contains(library.versions, version)
+			}
+		}
+	}
+	return false
+}
+
+func TestCheckHost(t *testing.T) {
+	host := &inventory.Host{Machine: "x86_64", Class: "ELF64", Endianness: "little", Kernel: "5.15.0-91-generic"}
+	interpreter := filepath.Join(t.TempDir(), "ld-linux-x86-64.so.2")
+	err := os.WriteFile(interpreter, nil, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hardware compat.Hardware
+		exe      compat.Executable
+
+		// The start of each reason the binary cannot run
+		reasons []string
+	}{
+		{
+			name:     "host",
+			hardware: compat.Hardware{Machine: "x86_64", Class: "ELF64", Endianness: "little"},
+			exe:      compat.Executable{OSABI: "none", Interpreter: interpreter, MinKernel: "3.2.0"},
+		},
+		{name: "unknown hardware"},
+		{name: "i386 on x86_64", hardware: compat.Hardware{Machine: "i386", Class: "ELF32"}},
+		{name: "other machine", hardware: compat.Hardware{Machine: "aarch64", Class: "ELF64"}, reasons: []string{"machine aarch64"}},
+		{name: "big endian", hardware: compat.Hardware{Endianness: "big"}, reasons: []string{"endianness big"}},
+		{name: "other os", exe: compat.Executable{OSABI: "freebsd"}, reasons: []string{"OS/ABI freebsd"}},
+		{
+			name:    "newer kernel and no interpreter",
+			exe:     compat.Executable{Interpreter: "/lib/ld-musl-x86_64.so.1", MinKernel: "6.8.0"},
+			reasons: []string{"interpreter /lib/ld-musl-x86_64.so.1", "kernel 5.15.0-91-generic is older than required 6.8.0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := compat.NewCompatibilitySpec()
+			spec.Hardware = test.hardware
+			spec.Executable = test.exe
+			reasons := checkHost(spec, host)
+			if len(reasons) != len(test.reasons) {
+				t.Fatalf("checkHost() = %q, want %q", reasons, test.reasons)
+			}
+			for i, reason := range reasons {
+				if !strings.HasPrefix(reason, test.reasons[i]) {
+					t.Errorf("checkHost() = %q, want %q", reason, test.reasons[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckLibraries(t *testing.T) {
+	old := hostLibrary{"/usr/lib64/libc.so.6", []string{"GLIBC_2.2.5", "GLIBC_2.17"}}
+	newer := hostLibrary{"/opt/glibc/lib/libc.so.6", []string{"GLIBC_2.2.5", "GLIBC_2.17", "GLIBC_2.34"}}
+	other := hostLibrary{"/opt/other/lib/libc.so.6", []string{"GLIBC_2.34"}}
+
+	tests := []struct {
+		name     string
+		host     hostLibraries
+		versions []string
+
+		found           map[string][]string
+		missing         []string
+		missingVersions map[string][]string
+	}{
+		{
+			name:     "first candidate",
+			host:     hostLibraries{"libc.so.6": {newer, old}},
+			versions: []string{"GLIBC_2.17", "GLIBC_2.34"},
+			found:    map[string][]string{"libc.so.6": {newer.path, old.path}},
+		},
+		{
+			name:     "later candidate",
+			host:     hostLibraries{"libc.so.6": {old, other, newer}},
+			versions: []string{"GLIBC_2.17", "GLIBC_2.34"},
+			found:    map[string][]string{"libc.so.6": {newer.path, old.path, other.path}},
+		},
+		{
+			name:            "no candidate has all versions",
+			host:            hostLibraries{"libc.so.6": {old, other}},
+			versions:        []string{"GLIBC_2.17", "GLIBC_2.34"},
+			found:           map[string][]string{"libc.so.6": {old.path, other.path}},
+			missingVersions: map[string][]string{"libc.so.6": {"GLIBC_2.34"}},
+		},
+		{
+			name:  "no versions needed",
+			host:  hostLibraries{"libc.so.6": {other, newer}},
+			found: map[string][]string{"libc.so.6": {other.path, newer.path}},
+		},
+		{
+			name:     "missing",
+			host:     hostLibraries{},
+			versions: []string{"GLIBC_2.34"},
+			missing:  []string{"libc.so.6"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec := compat.NewCompatibilitySpec()
+			spec.AddLibrary("libc.so.6")
+			for _, version := range test.versions {
+				spec.AddSymbol("libc.so.6", version)
+			}
+			result := compat.NewCompatibilityResult()
+			checkLibraries(spec, test.host, result)
+
+			if !maps.EqualFunc(result.Found, test.found, slices.Equal) {
+				t.Errorf("found %v, want %v", result.Found, test.found)
+			}
+			if !slices.Equal(result.Missing, test.missing) {
+				t.Errorf("missing %v, want %v", result.Missing, test.missing)
+			}
+			if !maps.EqualFunc(result.MissingVersions, test.missingVersions, slices.Equal) {
+				t.Errorf("missing versions %v, want %v", result.MissingVersions, test.missingVersions)
+			}
+		})
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"log"
 	"net"
 
+	"github.com/compspec/compat-lib/pkg/compat"
+	"github.com/compspec/compat-lib/pkg/inventory"
+	"github.com/compspec/compat-lib/pkg/oras"
 	"github.com/compspec/compat-lib/pkg/version"
 	pb "github.com/compspec/compat-lib/protos"
 
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 )
@@ -24,12 +28,89 @@ type Server struct {
 	listener net.Listener
 	name     string
 	version  string
+
+	// Libraries provided by the host
+	inventory *inventory.Inventory
+
+	// Media type and (optional) cache for artifacts requested by uri
+	mediaType string
+	cache     *oras.Cache
+	platform  *ocispec.Platform
+	verifier  *oras.Verifier
+	registry  oras.RegistryOptions
+}
+
+// Options for the compatibility server
+type Options struct {
+
+	// Extra prefixes (e.g., spack or module trees) with libraries
+	Prefixes []string
+
+	// Media type of the compatibility layer for artifacts pulled by uri
+	MediaType string
+
+	// Platform (os/arch[/variant]) to select from an image index,
+	// defaults to the host
+	Platform string
+
+	// Cache directory for pulled artifacts (unset to disable)
+	Cache string
+
+	// Tag expiry and size limit for the cache
+	CacheOptions oras.CacheOptions
+
+	// Registry connection (plain http, credentials, CA bundle)
+	Registry oras.RegistryOptions
+
+	// Public key (PEM) to verify signatures of artifacts pulled by uri
+	PublicKey string
+
+	// Refuse artifacts without a valid signature (and payloads, which
+	// cannot be verified)
+	Strict bool
 }
 
-// NewServer creates a new "scheduler" server
-// The scheduler server registers clusters and then accepts jobs
-func NewServer(serverName string) *Server {
-	return &Server{name: serverName, version: version.Version}
+// NewServer creates a new compatibility server
+// The host library inventory is built before the server is returned
+func NewServer(serverName string, options Options) (*Server, error) {
+	if options.MediaType == "" {
+		options.MediaType = oras.CompatibilityMediaType
+	}
+	platform, err := oras.ParsePlatform(options.Platform)
+	if err != nil {
+		return nil, err
+	}
+	var verifier *oras.Verifier
+	if options.PublicKey != "" {
+		verifier, err = oras.NewVerifier(options.PublicKey, options.Strict)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load public key")
+		}
+	} else if options.Strict {
+		return nil, errors.New("strict mode requires a public key")
+	}
+	var cache *oras.Cache
+	if options.Cache != "" {
+		cache, err = oras.NewCache(options.Cache, options.CacheOptions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create cache %s", options.Cache)
+		}
+	}
+	inv, err := inventory.NewInventory(options.Prefixes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build library inventory")
+	}
+	s := Server{
+		name:      serverName,
+		version:   version.Version,
+		inventory: inv,
+		mediaType: options.MediaType,
+		cache:     cache,
+		platform:  platform,
+		verifier:  verifier,
+		registry:  options.Registry,
+	}
+	return &s, nil
 }
 
 func (s *Server) String() string {
@@ -43,6 +124,12 @@ func (s *Server) GetVersion() string {
 	return s.version
 }
 
+// Refresh rebuilds the inventory of libraries on the host
+func (s *Server) Refresh() error {
+	log.Printf("🔄️ refreshing library inventory")
+	return s.inventory.Refresh()
+}
+
 func (s *Server) Stop() {
 	log.Printf("stopping server: %s", s.String())
 	if s.listener != nil {
@@ -87,25 +174,32 @@ func (s *Server) serve(_ context.Context, lis net.Listener) error {
 	return nil
 }
 
-// Register a new cluster with the server
+// CheckCompatibility checks that the libraries required by a spec resolve on the host
 func (s *Server) CheckCompatibility(_ context.Context, in *pb.CompatRequest) (*pb.Response, error) {
 	if in == nil {
 		return nil, errors.New("request is required")
 	}
 
-	fmt.Println(in)
-
-	//	log.Printf("📝️ received register: %s", in.Name)
-	//	response, err := s.db.RegisterCluster(in.Name, s.globalToken, nodes)
-	//	if err != nil {
-	//		return response, err
-	//	}
-
-	// // If we get here, now we can interact with the graph database to add the nodes
-	//
-	//	if response.Status == pb.RegisterResponse_REGISTER_SUCCESS {
-	//		err = s.graph.AddCluster(in.Name, &nodes, in.Subsystem)
-	//	}
-	response := &pb.Response{}
-	return response, nil
+	// A payload is used directly, otherwise we pull the uri
+	var spec *compat.CompatibiitySpec
+	var err error
+	if in.Payload != "" {
+		if s.verifier != nil && s.verifier.Required {
+			return errorResponse("strict mode only accepts signed artifacts by uri, a payload cannot be verified")
+		}
+		spec, err = compat.Load([]byte(in.Payload))
+		if err != nil {
+			return errorResponse(fmt.Sprintf("invalid compatibility spec: %s", err))
+		}
+	} else if in.Uri != "" {
+		log.Printf("📦️ loading artifact %s", in.Uri)
+		spec, err = oras.LoadArtifact(in.Uri, s.mediaType, s.platform, s.cache, s.verifier, s.registry)
+		if err != nil {
+			return errorResponse(fmt.Sprintf("cannot load artifact %s: %s", in.Uri, err))
+		}
+	} else {
+		return errorResponse("a compatibility spec payload or uri is required")
+	}
+	log.Printf("📝️ received request for %s", spec.Executable.Name)
+	return s.checkSpec(spec)
 }
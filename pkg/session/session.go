@@ -0,0 +1,249 @@
+package session
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	"github.com/compspec/compat-lib/pkg/logger"
+	"github.com/compspec/compat-lib/pkg/utils"
+	"github.com/compspec/compat-lib/pkg/version"
+)
+
+const (
+
+	// Files and directories in a session
+	ManifestFile = "manifest.json"
+	EventsName   = "events"
+	PidsDir      = "pids"
+
+	// Per-pid files kept open at once (the least recently used are closed)
+	maxOpenPids = 64
+)
+
+// Environment variables saved in the manifest, by name or prefix. Others
+// are left out, as they can hold credentials.
+var (
+	envNames = []string{
+		"PATH", "LD_LIBRARY_PATH", "LD_PRELOAD", "LIBRARY_PATH", "MODULEPATH",
+		"LOADEDMODULES", "USER", "PWD", "OMP_NUM_THREADS", "CUDA_VISIBLE_DEVICES",
+	}
+	envPrefixes = []string{"SLURM_", "FLUX_", "OMPI_", "PMI_", "SPACK_"}
+)
+
+// A Manifest describes the run that a session recorded
+type Manifest struct {
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Env      map[string]string `json:"env,omitempty"`
+	Hostname string            `json:"hostname"`
+	Start    time.Time         `json:"start"`
+	End      *time.Time        `json:"end,omitempty"`
+	ExitCode *int              `json:"exitCode,omitempty"`
+	Version  string            `json:"version"`
+
+	// Format and name of the event stream, and of the per-pid streams
+	// under the pids directory
+	Format string `json:"format"`
+	Events string `json:"events"`
+}
+
+// A Session records the events of one run to a directory, with the
+// event stream, a manifest, and a stream for each process
+type Session struct {
+	Manifest Manifest
+	dir      string
+	format   string
+	events   *logger.Logger
+
+	// Open per-pid streams, most recently used first
+	mutex  sync.Mutex
+	pids   map[int]*list.Element
+	recent *list.List
+	closed bool
+}
+
+var _ logger.EventLogger = (*Session)(nil)
+
+// pidStream is an open per-pid stream
+type pidStream struct {
+	pid    int
+	writer event.Writer
+}
+
+// New creates a session directory to record events in a format. The
+// directory must not already hold a session.
+func New(dir, format string) (*Session, error) {
+	if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+		return nil, fmt.Errorf("%s already has a recorded session", dir)
+	}
+	err := os.MkdirAll(filepath.Join(dir, PidsDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+	name := EventsName + extension(format)
+	events, err := logger.NewLogger(filepath.Join(dir, name), format)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	s := &Session{
+		Manifest: Manifest{
+			Hostname: hostname,
+			Start:    time.Now(),
+			Version:  version.Version,
+			Format:   format,
+			Events:   name,
+		},
+		dir:    dir,
+		format: format,
+		events: events,
+		pids:   map[int]*list.Element{},
+		recent: list.New(),
+	}
+	return s, s.WriteManifest()
+}
+
+// SetCommand sets the command (and its arguments) in the manifest, with
+// the environment variables that describe where it runs
+func (s *Session) SetCommand(args []string) error {
+	if len(args) > 0 {
+		s.Manifest.Command = args[0]
+		s.Manifest.Args = args[1:]
+	}
+	s.Manifest.Env = environment()
+	return s.WriteManifest()
+}
+
+// LogEvent logs an event to the event stream, and to the stream of its
+// process if it has one
+func (s *Session) LogEvent(e *event.Event) {
+	s.events.LogEvent(e)
+	if e.Pid == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	writer, err := s.pidWriter(e.Pid)
+	if err == nil {
+		err = writer.Write(e)
+	}
+	if err != nil {
+		log.Printf("warning: cannot record event for pid %d: %s", e.Pid, err)
+	}
+}
+
+// pidWriter returns the open stream for a pid, opening it (and closing
+// the least recently used) if needed
+func (s *Session) pidWriter(pid int) (event.Writer, error) {
+	if element, ok := s.pids[pid]; ok {
+		s.recent.MoveToFront(element)
+		return element.Value.(*pidStream).writer, nil
+	}
+	path := filepath.Join(s.dir, PidsDir, strconv.Itoa(pid)+extension(s.format))
+	writer, err := event.OpenFile(path, s.format)
+	if err != nil {
+		return nil, err
+	}
+	s.pids[pid] = s.recent.PushFront(&pidStream{pid: pid, writer: writer})
+	if s.recent.Len() > maxOpenPids {
+		oldest := s.recent.Remove(s.recent.Back()).(*pidStream)
+		delete(s.pids, oldest.pid)
+		oldest.writer.Close()
+	}
+	return writer, nil
+}
+
+// End records the end time of the run in the manifest
+func (s *Session) End() error {
+	end := time.Now()
+	s.Manifest.End = &end
+	return s.WriteManifest()
+}
+
+// Finish records the end time and exit code of the run in the manifest
+func (s *Session) Finish(exitCode int) error {
+	s.Manifest.ExitCode = &exitCode
+	return s.End()
+}
+
+// WriteManifest writes the manifest to the session directory, replacing
+// it at once so a reader of a running session never sees part of it
+func (s *Session) WriteManifest() error {
+	data, err := json.MarshalIndent(s.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(s.dir, ManifestFile), data)
+}
+
+// Path returns the session directory
+func (s *Session) Path() string {
+	return s.dir
+}
+
+// Close closes the event stream and the per-pid streams, after which
+// events are discarded
+func (s *Session) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	for element := s.recent.Front(); element != nil; element = element.Next() {
+		element.Value.(*pidStream).writer.Close()
+	}
+	s.pids = map[int]*list.Element{}
+	s.recent.Init()
+	return s.events.Close()
+}
+
+// Load reads the manifest of a session directory
+func Load(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	err = json.Unmarshal(data, &manifest)
+	return &manifest, err
+}
+
+// extension returns the file extension for an event format
+func extension(format string) string {
+	switch format {
+	case event.FormatJSON:
+		return ".jsonl"
+	case event.FormatBinary:
+		return ".bin"
+	}
+	return ".log"
+}
+
+// environment returns the environment variables saved in a manifest
+func environment() map[string]string {
+	env := map[string]string{}
+	for _, item := range os.Environ() {
+		name, value, _ := strings.Cut(item, "=")
+		keep := false
+		for _, prefix := range envPrefixes {
+			keep = keep || strings.HasPrefix(name, prefix)
+		}
+		for _, envName := range envNames {
+			keep = keep || name == envName
+		}
+		if keep {
+			env[name] = value
+		}
+	}
+	return env
+}
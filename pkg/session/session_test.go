@@ -0,0 +1,142 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/compspec/compat-lib/pkg/event"
+)
+
+func TestManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	s, err := New(dir, event.FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Format != event.FormatJSON || manifest.Events != "events.jsonl" || manifest.End != nil {
+		t.Errorf("new session has manifest %+v", manifest)
+	}
+
+	err = s.SetCommand([]string{"lmp", "-in", "in.lj"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Finish(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err = Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Command != "lmp" || len(manifest.Args) != 2 || manifest.Args[1] != "in.lj" {
+		t.Errorf("manifest has command %s %v, want lmp [-in in.lj]", manifest.Command, manifest.Args)
+	}
+	if manifest.ExitCode == nil || *manifest.ExitCode != 3 {
+		t.Errorf("manifest has exit code %v, want 3", manifest.ExitCode)
+	}
+	if manifest.End == nil || manifest.End.Before(manifest.Start) {
+		t.Errorf("manifest ends at %v, after a start at %s", manifest.End, manifest.Start)
+	}
+
+	// Each write replaces the manifest, and leaves no temporary file
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		switch entry.Name() {
+		case ManifestFile, manifest.Events, PidsDir:
+		default:
+			t.Errorf("session has unexpected file %s", entry.Name())
+		}
+	}
+
+	_, err = New(dir, event.FormatJSON)
+	if err == nil {
+		t.Error("New() recorded over an existing session")
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("SLURM_JOB_ID", "812")
+	t.Setenv("SPACK_ROOT", "/opt/spack")
+	t.Setenv("PATHS", "kept out")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "kept out")
+	t.Setenv("MY_SLURM_TOKEN", "kept out")
+
+	env := environment()
+	for name, value := range map[string]string{"PATH": "/usr/bin", "SLURM_JOB_ID": "812", "SPACK_ROOT": "/opt/spack"} {
+		if env[name] != value {
+			t.Errorf("environment has %s=%q, want %q", name, env[name], value)
+		}
+	}
+	for _, name := range []string{"PATHS", "AWS_SECRET_ACCESS_KEY", "MY_SLURM_TOKEN"} {
+		if _, ok := env[name]; ok {
+			t.Errorf("environment has %s", name)
+		}
+	}
+}
+
+func TestPidWriters(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, event.FormatBinary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One more pid than can be open closes the stream of the first, which
+	// is then opened again (and appended to) for its next event
+	for pid := 1; pid <= maxOpenPids+1; pid++ {
+		s.LogEvent(&event.Event{Type: event.Open, Path: "/in.lj", Pid: pid})
+	}
+	s.LogEvent(&event.Event{Type: event.Close, Path: "/in.lj", Pid: 1})
+	s.LogEvent(&event.Event{Type: event.Lookup, Path: "/etc"})
+
+	if len(s.pids) != maxOpenPids || s.recent.Len() != maxOpenPids {
+		t.Errorf("%d pid streams are open, want %d", len(s.pids), maxOpenPids)
+	}
+	if _, ok := s.pids[1]; !ok {
+		t.Error("the stream of the most recent pid is closed")
+	}
+	if _, ok := s.pids[2]; ok {
+		t.Error("the stream of the least recent pid is open")
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events after the close are discarded
+	s.LogEvent(&event.Event{Type: event.Open, Path: "/in.lj", Pid: 2})
+
+	counts := map[int]int{1: 2, 2: 1, maxOpenPids + 1: 1}
+	for pid, count := range counts {
+		path := filepath.Join(dir, PidsDir, strconv.Itoa(pid)+".bin")
+		events, err := event.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != count {
+			t.Errorf("pid %d has %d events, want %d", pid, len(events), count)
+		}
+	}
+	events, err := event.ReadFile(filepath.Join(dir, "events.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != maxOpenPids+3 {
+		t.Errorf("session has %d events, want %d", len(events), maxOpenPids+3)
+	}
+}
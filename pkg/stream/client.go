@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"context"
+	"io"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	pb "github.com/compspec/compat-lib/protos"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// A Handler receives each streamed event, and the number of events
+// dropped before it. Returning an error ends the stream.
+type Handler func(e *event.Event, dropped uint64) error
+
+// Subscribe streams events from a server to a handler until the server
+// closes the stream, the context is done, or the handler fails
+func Subscribe(ctx context.Context, host string, request *pb.StreamRequest, handler Handler) error {
+	if host == "" {
+		return errors.New("host is required")
+	}
+	conn, err := grpc.NewClient(host, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return errors.Wrapf(err, "unable to connect to %s", host)
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewEventServiceClient(conn).StreamEvents(ctx, request)
+	if err != nil {
+		return errors.Wrap(err, "unable to subscribe to events")
+	}
+	for {
+		message, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handler(FromProto(message), message.Dropped); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"github.com/compspec/compat-lib/pkg/event"
+	pb "github.com/compspec/compat-lib/protos"
+)
+
+// ToProto converts an event to its protobuf message
+func ToProto(e *event.Event) *pb.Event {
+	return &pb.Event{
+		Timestamp: e.Timestamp,
+		Type:      e.Type,
+		Path:      e.Path,
+		Fd:        int64(e.Fd),
+		Pid:       int64(e.Pid),
+		Errno:     int64(e.Errno),
+		Size:      e.Size,
+		Ppid:      int64(e.Ppid),
+		Uid:       int64(e.Uid),
+		Gid:       int64(e.Gid),
+		Comm:      e.Comm,
+		Exe:       e.Exe,
+		Offset:    e.Offset,
+		Target:    e.Target,
+		Duration:  e.Duration,
+		Handle:    e.Handle,
+
+		BytesRead:    e.BytesRead,
+		BytesWritten: e.BytesWritten,
+		Rank:         e.Rank,
+	}
+}
+
+// FromProto converts a protobuf message to an event
+func FromProto(message *pb.Event) *event.Event {
+	return &event.Event{
+		Timestamp: message.Timestamp,
+		Type:      message.Type,
+		Path:      message.Path,
+		Fd:        int(message.Fd),
+		Pid:       int(message.Pid),
+		Errno:     int(message.Errno),
+		Size:      message.Size,
+		Ppid:      int(message.Ppid),
+		Uid:       int(message.Uid),
+		Gid:       int(message.Gid),
+		Comm:      message.Comm,
+		Exe:       message.Exe,
+		Offset:    message.Offset,
+		Target:    message.Target,
+		Duration:  message.Duration,
+		Handle:    message.Handle,
+
+		BytesRead:    message.BytesRead,
+		BytesWritten: message.BytesWritten,
+		Rank:         message.Rank,
+	}
+}
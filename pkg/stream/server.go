@@ -0,0 +1,134 @@
+package stream
+
+import (
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/compspec/compat-lib/pkg/event"
+	pb "github.com/compspec/compat-lib/protos"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+const (
+	protocol = "tcp"
+
+	// Events buffered for a subscriber that does not ask for a size
+	DefaultBuffer = 1024
+
+	// Most events buffered for a subscriber that asks for a size, so one
+	// subscriber cannot take the memory of the recorder
+	MaxBuffer = 1 << 16
+
+	// How long to wait for subscribers to receive buffered events on stop
+	stopTimeout = 5 * time.Second
+)
+
+// Server streams the events of a filesystem to subscribers
+type Server struct {
+	pb.UnimplementedEventServiceServer
+	server   *grpc.Server
+	listener net.Listener
+	events   *event.Broadcaster
+	buffer   int
+}
+
+// NewServer creates a server for the events of a broadcaster. The buffer
+// is the default for subscribers that do not ask for a size.
+func NewServer(events *event.Broadcaster, buffer int) *Server {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	return &Server{events: events, buffer: buffer}
+}
+
+// Start listens on an address and serves subscribers in the background
+func (s *Server) Start(host string) error {
+	lis, err := net.Listen(protocol, host)
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen: %s", host)
+	}
+	s.listener = lis
+	s.server = grpc.NewServer()
+	pb.RegisterEventServiceServer(s.server, s)
+
+	log.Printf("event stream listening: %v", lis.Addr())
+	go func() {
+		if err := s.server.Serve(lis); err != nil {
+			log.Printf("event stream stopped: %s", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Stop closes the broadcaster and waits (for a short time) for
+// subscribers to receive the events in their buffers
+func (s *Server) Stop() {
+	s.events.Close()
+	if s.server == nil {
+		return
+	}
+	done := make(chan bool)
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		s.server.Stop()
+	}
+}
+
+// StreamEvents sends events to a subscriber until the broadcaster is
+// closed or the subscriber goes away. Each event has the count of events
+// dropped since the last one sent, when the subscriber falls behind.
+func (s *Server) StreamEvents(in *pb.StreamRequest, stream pb.EventService_StreamEventsServer) error {
+	var mask event.Mask
+	if len(in.Types) > 0 {
+		var err error
+		mask, err = event.ParseMask(strings.Join(in.Types, ","))
+		if err != nil {
+			return err
+		}
+	}
+	buffer := min(int(in.Buffer), MaxBuffer)
+	if buffer <= 0 {
+		buffer = s.buffer
+	}
+	subscription := s.events.Subscribe(buffer, mask)
+	defer s.events.Unsubscribe(subscription)
+
+	sent, reported := 0, uint64(0)
+	defer func() {
+		log.Printf("event subscriber finished: %d sent, %d dropped", sent, subscription.Dropped())
+	}()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-subscription.Events():
+			if !ok {
+				return nil
+			}
+			message := ToProto(e)
+			dropped := subscription.Dropped()
+			message.Dropped = dropped - reported
+			reported = dropped
+			if err := stream.Send(message); err != nil {
+				return err
+			}
+			sent++
+		}
+	}
+}
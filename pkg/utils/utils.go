@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -38,6 +40,22 @@ func FullPath(path string) (string, error) {
 	return path, nil
 }
 
+// SplitInts splits a comma separated list of integers
+func SplitInts(items string) ([]int, error) {
+	values := []int{}
+	for _, item := range strings.Split(items, ",") {
+		if item == "" {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return values, fmt.Errorf("%s is not an integer", item)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 // chunkify a count of processors across sockets
 func Chunkify(items []string, count int) [][]string {
 	var chunks [][]string
@@ -99,6 +117,31 @@ func CopyFile(src, dest string) error {
 	return os.WriteFile(dest, input, 0644)
 }
 
+// WriteFileAtomic writes to a temporary file and renames it into place,
+// so a reader never sees part of the file
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-"+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
 // ArrayContainsString determines if a string is in an array
 // We return an array of invalid names in case the calling function needs
 func StringArrayIsSubset(contenders, items []string) ([]string, bool) {
@@ -164,3 +207,16 @@ func RandomSort(items []string) []string {
 	}
 	return items
 }
+
+// CString reads a null terminated string at an offset (e.g., in an ELF
+// string table), and is empty past the end of the data
+func CString(data []byte, offset uint32) string {
+	if int(offset) >= len(data) {
+		return ""
+	}
+	data = data[offset:]
+	if end := bytes.IndexByte(data, 0); end >= 0 {
+		data = data[:end]
+	}
+	return string(data)
+}
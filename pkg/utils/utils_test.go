@@ -0,0 +1,19 @@
+package utils
+
+import "testing"
+
+func TestCString(t *testing.T) {
+	data := []byte("\x00libc.so.6\x00GLIBC_2.34")
+	for offset, want := range map[uint32]string{
+		0:  "",
+		1:  "libc.so.6",
+		5:  ".so.6",
+		11: "GLIBC_2.34",
+		21: "",
+		99: "",
+	} {
+		if got := CString(data, offset); got != want {
+			t.Errorf("CString(%d) = %q, want %q", offset, got, want)
+		}
+	}
+}
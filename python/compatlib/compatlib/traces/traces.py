@@ -95,21 +95,24 @@ class TraceSet:
                     )
         return events
 
-    def iter_events(self, operations=None):
+    def iter_events(self, operations=None, failed=False):
         """
         Iterate through files and yield event object
 
         This function by default yields all event types, and
         it is up to the calling client to filter down to those of interest.
+        Failed operations (e.g., a lookup of a missing file) are skipped
+        unless failed is True.
         """
         for filename in self.files:
             basename = os.path.basename(filename)
             for line in utils.read_file(filename).split("\n"):
                 if not line:
                     continue
-                # date, time, golang-file  timestamp function path
-                # 2024/11/08 10:46:19 recorder.go:46: 1731062779714551943 Lookup     /etc
-                parts = [x for x in line.split() if x]
+                # date, time, source, timestamp, function, and the path, with
+                # the fd and key=value fields (e.g., errno=2) after tabs
+                # 2024/11/08 10:46:19 fs-record: 1731062779714551943 Open      /etc\t3\tpid=12
+                parts = line.split(None, 5)
                 function = parts[4]
 
                 # Custom filter for event types
@@ -117,13 +120,25 @@ class TraceSet:
                     continue
 
                 # Path will also be normalized to remove so version
-                path = parts[5]
+                fields = [x.strip() for x in parts[5].split("\t")] if len(parts) > 5 else [""]
+                path = fields[0]
                 timestamp = int(parts[3])
 
                 # If we have a file descriptor, it's an open or close
                 file_descriptor = None
-                if len(parts) > 6:
-                    file_descriptor = parts[6]
+                values = {}
+                for field in fields[1:]:
+                    if "=" in field:
+                        key, value = field.split("=", 1)
+                        values[key] = value
+                    elif field:
+                        file_descriptor = field
+
+                # A failed operation has the errno
+                errno = int(values.get("errno", 0))
+                if errno and not failed:
+                    continue
+
                 yield Event(
                     filename=filename,
                     basename=basename,
@@ -131,6 +146,11 @@ class TraceSet:
                     path=path,
                     timestamp=timestamp,
                     file_descriptor=file_descriptor,
+                    errno=errno,
+                    duration=int(values.get("dur", 0)),
+                    pid=int(values.get("pid", 0)),
+                    uid=int(values.get("uid", 0)),
+                    comm=values.get("comm"),
                     normalized_path=utils.normalize_soname(path),
                 )
 